@@ -0,0 +1,166 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+type CallsignKind int
+
+const (
+	UnknownCallsign CallsignKind = iota
+	AirlineCallsign
+	MilitaryCallsign
+	VirtualAirlineCallsign
+	RegistrationCallsign
+)
+
+// Callsign is the result of ClassifyCallsign.
+type Callsign struct {
+	Kind CallsignKind
+
+	// Prefix is the ICAO designator for airline and military callsigns,
+	// and the callsign prefix shared by similar aircraft for registrations
+	// (for instance "DE" for German single-engine aircraft below 2 t).
+	Prefix string
+
+	Country string // registrations only
+}
+
+// nationalityMarks maps registration prefixes to countries. See
+// https://en.wikipedia.org/wiki/List_of_aircraft_registration_prefixes
+var nationalityMarks = map[string]string{
+	"4X": "Israel",
+	"9A": "Croatia",
+	"9H": "Malta",
+	"A6": "United Arab Emirates",
+	"B":  "China",
+	"C":  "Canada",
+	"CS": "Portugal",
+	"D":  "Germany",
+	"EC": "Spain",
+	"EI": "Ireland",
+	"ES": "Estonia",
+	"F":  "France",
+	"G":  "United Kingdom",
+	"HA": "Hungary",
+	"HB": "Switzerland",
+	"I":  "Italy",
+	"JA": "Japan",
+	"LN": "Norway",
+	"LX": "Luxembourg",
+	"LY": "Lithuania",
+	"LZ": "Bulgaria",
+	"N":  "United States",
+	"OE": "Austria",
+	"OH": "Finland",
+	"OK": "Czech Republic",
+	"OM": "Slovakia",
+	"OO": "Belgium",
+	"OY": "Denmark",
+	"PH": "Netherlands",
+	"S5": "Slovenia",
+	"SE": "Sweden",
+	"SP": "Poland",
+	"SX": "Greece",
+	"TC": "Turkey",
+	"TF": "Iceland",
+	"VH": "Australia",
+	"YL": "Latvia",
+	"YR": "Romania",
+	"ZK": "New Zealand",
+	"ZS": "South Africa",
+}
+
+// classLetterCountries are countries whose registrations encode the aircraft
+// class in the first letter after the nationality mark, like D-E for single
+// engine aircraft below 2 t in Germany.
+var classLetterCountries = map[string]bool{
+	"D": true,
+}
+
+var (
+	nNumberPattern      = regexp.MustCompile(`^N[1-9][0-9]{0,4}[A-Z]{0,2}$`)
+	lettersPattern      = regexp.MustCompile(`^[A-Z]+$`)
+	flightNumberPattern = regexp.MustCompile(`^[A-Z]{3}[0-9][A-Z0-9]*$`)
+
+	// militaryPattern matches the descriptions of military operators in
+	// ICAO_Airlines.txt, such as "German Air Force - Germany".
+	militaryPattern = regexp.MustCompile(`(?i)\b(air ?force|army|navy|military|militaire|forces)\b`)
+)
+
+// ClassifyCallsign tells registrations, airline, military and virtual airline
+// callsigns apart. Airlines are recognized using the ICAO_Airlines.txt file
+// and vPilot's airline codes; unknown three letter designators are assumed to
+// be virtual airlines.
+func (d *Database) ClassifyCallsign(s string) Callsign {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	if nNumberPattern.MatchString(s) {
+		return Callsign{Kind: RegistrationCallsign, Prefix: "N", Country: nationalityMarks["N"]}
+	}
+
+	if c, ok := classifyRegistration(s); ok {
+		return c
+	}
+
+	if !flightNumberPattern.MatchString(s) {
+		return Callsign{Kind: UnknownCallsign}
+	}
+
+	code := parseCallsign(s)
+	switch {
+	case d.military[code]:
+		return Callsign{Kind: MilitaryCallsign, Prefix: code}
+	case d.AirlineNames[code] != "", d.AirlineCodes[code]:
+		return Callsign{Kind: AirlineCallsign, Prefix: code}
+	default:
+		return Callsign{Kind: VirtualAirlineCallsign, Prefix: code}
+	}
+}
+
+// classifyRegistration recognizes registrations that consist of a nationality
+// mark and letters, with or without dash: "D-EABC", "DEABC", "HBABC".
+func classifyRegistration(s string) (Callsign, bool) {
+	var splits [][2]string
+	if i := strings.IndexByte(s, '-'); i > 0 {
+		splits = append(splits, [2]string{s[:i], s[i+1:]})
+	} else if len(s) > 2 {
+		// Try "HB-ABC" before "H-BABC".
+		splits = append(splits, [2]string{s[:2], s[2:]}, [2]string{s[:1], s[1:]})
+	}
+
+	for _, x := range splits {
+		mark, rest := x[0], x[1]
+		country, ok := nationalityMarks[mark]
+		if !ok || len(mark)+len(rest) != 5 || !lettersPattern.MatchString(rest) {
+			continue
+		}
+
+		prefix := mark
+		if classLetterCountries[mark] {
+			prefix += rest[:1]
+		}
+		return Callsign{Kind: RegistrationCallsign, Prefix: prefix, Country: country}, true
+	}
+
+	return Callsign{}, false
+}
+
+// isRegistrationPrefix tells whether a key of Database.Wanted is a
+// registration prefix rather than an ICAO airline designator.
+func isRegistrationPrefix(prefix string) bool {
+	return len(prefix) < 3
+}
+
+// RuleSetName returns the name of the rule set that contains the rules for
+// a key of Database.Wanted, and the airline name under which matching models
+// are indexed. Models of registration callsigns are looked up among the
+// models without atc_airline.
+func (d *Database) RuleSetName(prefix string) (name, airlineName string) {
+	if isRegistrationPrefix(prefix) {
+		return "Registration " + prefix, ""
+	}
+	name = d.AirlineNames[prefix]
+	return name, name
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestClassifyCallsign(t *testing.T) {
+	f, err := os.Open("../EuroScope/EDBB/ICAO_Airlines.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &Database{}
+	err = db.ReadEuroScopeICAO(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		given string
+		want  Callsign
+	}{
+		{"", Callsign{}},
+		{"X", Callsign{}},
+		{"DLH", Callsign{}},
+		{"DLH543", Callsign{Kind: AirlineCallsign, Prefix: "DLH"}},
+		{"DLH89H", Callsign{Kind: AirlineCallsign, Prefix: "DLH"}},
+		{"CFG123", Callsign{Kind: AirlineCallsign, Prefix: "CFG"}},
+		{"DEA123", Callsign{Kind: AirlineCallsign, Prefix: "DEA"}},
+		{"GAF003", Callsign{Kind: MilitaryCallsign, Prefix: "GAF"}},
+		{"RRR4455", Callsign{Kind: MilitaryCallsign, Prefix: "RRR"}},
+		{"XQZ123", Callsign{Kind: VirtualAirlineCallsign, Prefix: "XQZ"}},
+		{"D-EABC", Callsign{Kind: RegistrationCallsign, Prefix: "DE", Country: "Germany"}},
+		{"DEABC", Callsign{Kind: RegistrationCallsign, Prefix: "DE", Country: "Germany"}},
+		{"DIABC", Callsign{Kind: RegistrationCallsign, Prefix: "DI", Country: "Germany"}},
+		{"HBABC", Callsign{Kind: RegistrationCallsign, Prefix: "HB", Country: "Switzerland"}},
+		{"HB-ABC", Callsign{Kind: RegistrationCallsign, Prefix: "HB", Country: "Switzerland"}},
+		{"GABCD", Callsign{Kind: RegistrationCallsign, Prefix: "G", Country: "United Kingdom"}},
+		{"N123AB", Callsign{Kind: RegistrationCallsign, Prefix: "N", Country: "United States"}},
+		{"N1", Callsign{Kind: RegistrationCallsign, Prefix: "N", Country: "United States"}},
+		{"EDDT_TWR", Callsign{}},
+		{"DEABCD", Callsign{}},
+	}
+
+	for _, tc := range cases {
+		if got := db.ClassifyCallsign(tc.given); got != tc.want {
+			t.Errorf("ClassifyCallsign(%q) == %+v, want %+v", tc.given, got, tc.want)
+		}
+	}
+}
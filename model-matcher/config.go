@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,8 +16,30 @@ type Config map[string]map[string]string
 
 type InstalledModel struct {
 	AirlineName string
-	Model       string
+	AirlineCode string // ICAO designator; CSL models only
+	Model       string // atc_model; the ICAO type designator, hopefully
 	Title       string
+	CSL         bool // X-Plane CSL model rather than FSX SimObject
+
+	ATCType      string   // [General] atc_type, e.g. "BOEING"
+	ATCID        string   // registration
+	ParkingCodes []string // atc_parking_codes; usually airline codes
+	UIType       string
+	UIVariation  string
+	Description  string
+
+	// Folders next to aircraft.cfg containing the model, textures and panel
+	// of this variant, such as "model" or "texture.DLH".
+	ModelFolder   string
+	TextureFolder string
+	PanelFolder   string
+
+	// Problem describes why the model cannot be used, or is empty.
+	Problem string
+
+	// CSLLines are the lines of xsb_aircraft.txt that define a CSL model,
+	// except for ICAO, AIRLINE and LIVERY.
+	CSLLines []string `json:",omitempty"`
 }
 
 var airlineFixes = map[string]string{
@@ -25,7 +49,8 @@ var airlineFixes = map[string]string{
 func AircraftConfig(filename string) ([]InstalledModel, error) {
 	// config file reference: https://msdn.microsoft.com/en-us/library/cc526949.aspx
 
-	id := filepath.Base(filepath.Dir(filename))
+	dir := filepath.Dir(filename)
+	id := filepath.Base(dir)
 	if id == "" {
 		return nil, errors.New("Malformed filename: " + filename)
 	}
@@ -34,6 +59,7 @@ func AircraftConfig(filename string) ([]InstalledModel, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
 	variants, err := ParseIni(f)
 	if err != nil {
@@ -42,10 +68,15 @@ func AircraftConfig(filename string) ([]InstalledModel, error) {
 
 	atcModel := variants["general"]["atc_model"]
 	if atcModel == "" {
-		fmt.Println("Empty atc_model: ", filename)
+		slog.Warn("Empty atc_model", "file", filename)
 		return nil, nil
 	}
 
+	folders, err := folderNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	var models []InstalledModel
 
 	for n, section := range variants {
@@ -57,19 +88,36 @@ func AircraftConfig(filename string) ([]InstalledModel, error) {
 			Title:       section["title"],
 			AirlineName: section["atc_airline"],
 			Model:       atcModel,
+
+			ATCType:      variants["general"]["atc_type"],
+			ATCID:        section["atc_id"],
+			ParkingCodes: splitList(section["atc_parking_codes"]),
+			UIType:       section["ui_type"],
+			UIVariation:  section["ui_variation"],
+			Description:  section["description"],
+
+			ModelFolder:   folderName("model", section["model"]),
+			TextureFolder: folderName("texture", section["texture"]),
+			PanelFolder:   folderName("panel", section["panel"]),
 		}
 
 		if fix := airlineFixes[m.AirlineName]; fix != "" {
 			m.AirlineName = fix
 		}
 
+		// Panels are optional; AI aircraft don't have one.
 		switch {
-		case m.Title == "":
-			fmt.Println("Empty title: ", filename)
-		case m.AirlineName == "":
-			// Happens all the time for stock models
-			// fmt.Println("Empty atc_airline: ", filename)
-		default:
+		case !folders[strings.ToLower(m.ModelFolder)]:
+			m.Problem = "missing folder " + m.ModelFolder
+		case !folders[strings.ToLower(m.TextureFolder)]:
+			m.Problem = "missing folder " + m.TextureFolder
+		}
+
+		// Models without atc_airline (all the time for stock models)
+		// are used for registration callsigns.
+		if m.Title == "" {
+			slog.Warn("Empty title", "file", filename)
+		} else {
 			models = append(models, m)
 		}
 	}
@@ -77,6 +125,42 @@ func AircraftConfig(filename string) ([]InstalledModel, error) {
 	return models, nil
 }
 
+// folderName returns the folder that is referenced by a model=, texture= or
+// panel= entry. An empty value refers to the folder without suffix.
+func folderName(kind, value string) string {
+	if value == "" {
+		return kind
+	}
+	return kind + "." + value
+}
+
+// folderNames returns the lower-cased names of all directories in dir. Files
+// are looked up case-insensitively by FSX.
+func folderNames(dir string) (map[string]bool, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() {
+			names[strings.ToLower(fi.Name())] = true
+		}
+	}
+	return names, nil
+}
+
+func splitList(s string) []string {
+	var x []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			x = append(x, v)
+		}
+	}
+	return x
+}
+
 func ParseIni(r io.Reader) (Config, error) {
 	cfg := make(Config)
 	scanner := bufio.NewScanner(r)
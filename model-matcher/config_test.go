@@ -1,7 +1,11 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -102,3 +106,81 @@ foo = bar`,
 		})
 	}
 }
+
+func TestAircraftConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"Model.A320", "texture.DLH", "texture.dlh_2"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fname := filepath.Join(dir, "aircraft.cfg")
+	err = ioutil.WriteFile(fname, []byte(`[fltsim.0]
+title=WoA Lufthansa A320 D-AIPA
+sim=A320
+model=a320
+panel=
+texture=DLH
+atc_airline=LUFTHANSA
+atc_id=D-AIPA
+atc_parking_codes=DLH, LH
+ui_type=A320-211
+ui_variation=Lufthansa
+description=World of AI
+
+[fltsim.1]
+title=WoA Lufthansa A320 D-AIPB
+model=a320
+texture=DLH_3
+atc_airline=LUFTHANSA
+
+[General]
+atc_type=AIRBUS
+atc_model=A320
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := AircraftConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Title < models[j].Title })
+
+	want := []InstalledModel{
+		{
+			AirlineName:   "LUFTHANSA",
+			Model:         "A320",
+			Title:         "WoA Lufthansa A320 D-AIPA",
+			ATCType:       "AIRBUS",
+			ATCID:         "D-AIPA",
+			ParkingCodes:  []string{"DLH", "LH"},
+			UIType:        "A320-211",
+			UIVariation:   "Lufthansa",
+			Description:   "World of AI",
+			ModelFolder:   "model.a320",
+			TextureFolder: "texture.DLH",
+			PanelFolder:   "panel",
+		},
+		{
+			AirlineName:   "LUFTHANSA",
+			Model:         "A320",
+			Title:         "WoA Lufthansa A320 D-AIPB",
+			ATCType:       "AIRBUS",
+			ModelFolder:   "model.a320",
+			TextureFolder: "texture.DLH_3",
+			PanelFolder:   "panel",
+			Problem:       "missing folder texture.DLH_3",
+		},
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("%+v != %+v", models, want)
+	}
+}
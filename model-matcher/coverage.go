@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type MatchKind int
+
+const (
+	NoMatch MatchKind = iota
+	SubstituteMatch
+	ExactMatch
+)
+
+// Coverage counts how many of the observed aircraft would be displayed with
+// an exact model, a substitute of a similar type, or not at all.
+type Coverage struct {
+	Snapshots int
+	Total     [3]int // by MatchKind
+	ByAirline map[string]*[3]int
+	ByType    map[string]*[3]int
+	Missing   map[[2]string]int // airline code and type of flights without model
+}
+
+// MatchesLocation tells whether a flight departs from or arrives at location,
+// which is a comma separated list of ICAO airport codes or prefixes thereof,
+// such as "EDDT", "ED" (Germany), or "EDDH,EDDW,EDDV" (a few airports of a
+// FIR). An empty location matches all flights.
+func MatchesLocation(x APIStation, location string) bool {
+	if location == "" {
+		return true
+	}
+	for _, prefix := range strings.Split(strings.ToUpper(location), ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		if strings.HasPrefix(x.Origin, prefix) || strings.HasPrefix(x.Destination, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ComputeCoverage determines the coverage of the installed models for the
+// flights in the snapshots that match location. Each combination of callsign
+// and aircraft is counted once, no matter in how many snapshots it appears.
+func ComputeCoverage(index map[string]map[string][]string, db *Database, snapshots []Snapshot, location string) *Coverage {
+	c := &Coverage{
+		Snapshots: len(snapshots),
+		ByAirline: make(map[string]*[3]int),
+		ByType:    make(map[string]*[3]int),
+		Missing:   make(map[[2]string]int),
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range snapshots {
+		for _, x := range s.Stations {
+			if !MatchesLocation(x, location) || seen[x.Callsign+" "+x.Aircraft] {
+				continue
+			}
+			seen[x.Callsign+" "+x.Aircraft] = true
+
+			ac := db.designator(x.Aircraft)
+			if ac == "" {
+				continue
+			}
+
+			al := "?"
+			kind := NoMatch
+			cs := db.ClassifyCallsign(x.Callsign)
+			switch cs.Kind {
+			case AirlineCallsign, MilitaryCallsign, RegistrationCallsign:
+				al = cs.Prefix
+				_, alName := db.RuleSetName(cs.Prefix)
+				switch titles, substitute := findModels(index, db, alName, ac); {
+				case len(titles) == 0:
+				case substitute:
+					kind = SubstituteMatch
+				default:
+					kind = ExactMatch
+				}
+			}
+
+			if kind == NoMatch && cs.Kind == AirlineCallsign {
+				c.Missing[[2]string{al, ac}]++
+			}
+			c.Total[kind]++
+			if c.ByAirline[al] == nil {
+				c.ByAirline[al] = new([3]int)
+			}
+			c.ByAirline[al][kind]++
+			if c.ByType[ac] == nil {
+				c.ByType[ac] = new([3]int)
+			}
+			c.ByType[ac][kind]++
+		}
+	}
+
+	return c
+}
+
+func (c *Coverage) Print(w io.Writer) {
+	total := c.Total[ExactMatch] + c.Total[SubstituteMatch] + c.Total[NoMatch]
+	fmt.Fprintf(w, "%d aircraft in %d snapshots\n", total, c.Snapshots)
+	if total == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "exact\t%d\t%.1f%%\t\n", c.Total[ExactMatch], percent(c.Total[ExactMatch], total))
+	fmt.Fprintf(tw, "substitute\t%d\t%.1f%%\t\n", c.Total[SubstituteMatch], percent(c.Total[SubstituteMatch], total))
+	fmt.Fprintf(tw, "none\t%d\t%.1f%%\t\n", c.Total[NoMatch], percent(c.Total[NoMatch], total))
+	tw.Flush()
+
+	for _, x := range []struct {
+		title  string
+		counts map[string]*[3]int
+	}{
+		{"AIRLINE", c.ByAirline},
+		{"TYPE", c.ByType},
+	} {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "%s\tEXACT\tSUBST\tNONE\tCOVERAGE\t\n", x.title)
+		for _, k := range sortedByMisses(x.counts) {
+			n := x.counts[k]
+			sum := n[ExactMatch] + n[SubstituteMatch] + n[NoMatch]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\t\n", k, n[ExactMatch], n[SubstituteMatch], n[NoMatch],
+				percent(n[ExactMatch]+n[SubstituteMatch], sum))
+		}
+		tw.Flush()
+	}
+}
+
+// WriteMissing writes the airlines and types without model as CSV, those
+// with most flights first, for woai-install's gaps command.
+func (c *Coverage) WriteMissing(w io.Writer) error {
+	keys := make([][2]string, 0, len(c.Missing))
+	for k := range c.Missing {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := c.Missing[keys[i]], c.Missing[keys[j]]
+		if a != b {
+			return a > b
+		}
+		return keys[i][0]+" "+keys[i][1] < keys[j][0]+" "+keys[j][1]
+	})
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"airline", "type", "flights"})
+	for _, k := range keys {
+		cw.Write([]string{k[0], k[1], strconv.Itoa(c.Missing[k])})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// sortedByMisses returns the keys of counts, those with most missing models
+// first.
+func sortedByMisses(counts map[string]*[3]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := counts[keys[i]], counts[keys[j]]
+		if a[NoMatch] != b[NoMatch] {
+			return a[NoMatch] > b[NoMatch]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComputeCoverage(t *testing.T) {
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("DLH\tLufthansa - Germany\tLUFTHANSA\nBER\tAir Berlin - Germany\tAIR BERLIN\n"))
+	db.ReadSimilarTypes(strings.NewReader("A319 A320 A321\n"))
+
+	index := map[string]map[string][]string{
+		"LUFTHANSA": {
+			"A320": {"WoA Lufthansa A320"},
+			"B744": {"WoA Lufthansa B744"},
+		},
+	}
+
+	snapshots := []Snapshot{
+		{Stations: []APIStation{
+			{Callsign: "DLH1", Aircraft: "A320/L", Origin: "EDDT", Destination: "EDDF"},
+			{Callsign: "DLH2", Aircraft: "H/B744/L", Origin: "EDDF", Destination: "KJFK"},
+			{Callsign: "DLH3", Aircraft: "A321/L", Origin: "EDDM", Destination: "EDDT"},
+		}},
+		{Stations: []APIStation{
+			{Callsign: "DLH1", Aircraft: "A320/L", Origin: "EDDT", Destination: "EDDF"},
+			{Callsign: "BER123", Aircraft: "A320/L", Origin: "EDDT", Destination: "LEPA"},
+		}},
+	}
+
+	c := ComputeCoverage(index, db, snapshots, "EDDT")
+	if want := [3]int{NoMatch: 1, SubstituteMatch: 1, ExactMatch: 1}; c.Total != want {
+		t.Errorf("Total == %v, want %v", c.Total, want)
+	}
+	if want := [3]int{SubstituteMatch: 1, ExactMatch: 1}; *c.ByAirline["DLH"] != want {
+		t.Errorf("ByAirline[DLH] == %v, want %v", *c.ByAirline["DLH"], want)
+	}
+	if want := [3]int{NoMatch: 1, ExactMatch: 1}; *c.ByType["A320"] != want {
+		t.Errorf("ByType[A320] == %v, want %v", *c.ByType["A320"], want)
+	}
+	if c.ByType["B744"] != nil {
+		t.Errorf("ByType[B744] == %v, want nil", *c.ByType["B744"])
+	}
+	if want := map[[2]string]int{{"BER", "A320"}: 1}; !reflect.DeepEqual(c.Missing, want) {
+		t.Errorf("Missing == %v, want %v", c.Missing, want)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteMissing(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "airline,type,flights\nBER,A320,1\n"; got != want {
+		t.Errorf("WriteMissing() wrote %q, want %q", got, want)
+	}
+
+	c = ComputeCoverage(index, db, snapshots, "")
+	if want := [3]int{NoMatch: 1, SubstituteMatch: 1, ExactMatch: 2}; c.Total != want {
+		t.Errorf("Total == %v, want %v", c.Total, want)
+	}
+}
+
+func TestMatchesLocation(t *testing.T) {
+	x := APIStation{Origin: "EDDT", Destination: "LEPA"}
+	cases := []struct {
+		location string
+		want     bool
+	}{
+		{"", true},
+		{"EDDT", true},
+		{"eddt", true},
+		{"ED", true},
+		{"LE", true},
+		{"EDDF", false},
+		{"EDDH,EDDW, EDDT", true},
+		{"EDDH,EDDW", false},
+	}
+
+	for _, tc := range cases {
+		if got := MatchesLocation(x, tc.location); got != tc.want {
+			t.Errorf("MatchesLocation(%+v, %q) == %v, want %v", x, tc.location, got, tc.want)
+		}
+	}
+}
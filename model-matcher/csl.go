@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CSLPaths returns the paths of all xsb_aircraft.txt files in the CSL
+// directories dirs, and in their immediate subdirectories. The packages
+// written by cslFormat are skipped.
+func CSLPaths(dirs []string) ([]string, error) {
+	var paths []string
+	for _, dir := range dirs {
+		for _, pattern := range []string{"xsb_aircraft.txt", "*/xsb_aircraft.txt"} {
+			x, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range x {
+				if !strings.HasPrefix(filepath.Base(filepath.Dir(path)), cslPackagePrefix) {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	return paths, nil
+}
+
+// CSLPackage reads the models of an X-Plane CSL package from its
+// xsb_aircraft.txt file. Each OBJ8_AIRCRAFT (or, in old packages, AIRCRAFT or
+// OBJECT) line starts a new model; ICAO, AIRLINE and LIVERY lines declare its
+// type and airline.
+//
+// Model titles are the export name of the package and the name of the model,
+// separated by a slash, like xPilot displays them. The other lines of a model
+// are kept in CSLLines, so that cslFormat can copy it.
+func CSLPackage(filename string) ([]InstalledModel, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pkg := filepath.Base(filepath.Dir(filename))
+
+	var models []InstalledModel
+	var m *InstalledModel
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "EXPORT_NAME":
+			if len(fields) > 1 {
+				pkg = fields[1]
+			}
+		case "OBJ8_AIRCRAFT", "AIRCRAFT", "OBJECT":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: missing model name", filename, n)
+			}
+			models = append(models, InstalledModel{
+				Title:    pkg + "/" + fields[len(fields)-1],
+				CSL:      true,
+				CSLLines: []string{strings.Join(fields, " ")},
+			})
+			m = &models[len(models)-1]
+		case "ICAO", "AIRLINE", "LIVERY":
+			if m == nil {
+				return nil, fmt.Errorf("%s:%d: %s before aircraft", filename, n, fields[0])
+			}
+			if len(fields) > 1 {
+				m.Model = fields[1]
+			}
+			if len(fields) > 2 {
+				m.AirlineCode = fields[2]
+			}
+			if len(fields) > 3 {
+				m.ATCID = fields[3]
+			}
+		case "DEPENDENCY":
+		default:
+			// OBJ8, TEXTURE, VERT_OFFSET and so on.
+			if m != nil {
+				m.CSLLines = append(m.CSLLines, strings.Join(fields, " "))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Models without ICAO line cannot be matched.
+	x := models[:0]
+	for _, m := range models {
+		if m.Model != "" {
+			x = append(x, m)
+		}
+	}
+	return x, nil
+}
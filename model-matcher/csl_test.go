@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCSLPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "BB_Airbus", "xsb_aircraft.txt")
+	if err := os.Mkdir(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(fname, []byte(`EXPORT_NAME BB_A320
+DEPENDENCY __Bluebell_Airbus
+
+# Lufthansa
+OBJ8_AIRCRAFT A320_DLH
+OBJ8 SOLID YES __BB_A320:A320/A320.obj DLH.png
+AIRLINE A320 DLH
+
+OBJ8_AIRCRAFT A320_DLH_DAIPA
+OBJ8 SOLID YES __BB_A320:A320/A320.obj DAIPA.png
+LIVERY A320 DLH DAIPA
+
+OBJ8_AIRCRAFT A320
+OBJ8 SOLID YES __BB_A320:A320/A320.obj A320.png
+ICAO A320
+
+OBJ8_AIRCRAFT UNUSED
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Packages written by cslFormat are no installed models.
+	generated := filepath.Join(dir, cslPackagePrefix+"Lufthansa", "xsb_aircraft.txt")
+	if err := os.Mkdir(filepath.Dir(generated), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(generated, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := CSLPaths([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{fname}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("CSLPaths() == %v, want %v", paths, want)
+	}
+
+	models, err := CSLPackage(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InstalledModel{
+		{Title: "BB_A320/A320_DLH", Model: "A320", AirlineCode: "DLH", CSL: true, CSLLines: []string{
+			"OBJ8_AIRCRAFT A320_DLH",
+			"OBJ8 SOLID YES __BB_A320:A320/A320.obj DLH.png",
+		}},
+		{Title: "BB_A320/A320_DLH_DAIPA", Model: "A320", AirlineCode: "DLH", ATCID: "DAIPA", CSL: true, CSLLines: []string{
+			"OBJ8_AIRCRAFT A320_DLH_DAIPA",
+			"OBJ8 SOLID YES __BB_A320:A320/A320.obj DAIPA.png",
+		}},
+		{Title: "BB_A320/A320", Model: "A320", CSL: true, CSLLines: []string{
+			"OBJ8_AIRCRAFT A320",
+			"OBJ8 SOLID YES __BB_A320:A320/A320.obj A320.png",
+		}},
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("%+v != %+v", models, want)
+	}
+}
@@ -10,9 +10,20 @@ import (
 )
 
 type Database struct {
-	Wanted       map[string]map[string]bool // Airline to Aircraft to Urgent
-	AirlineNames map[string]string
-	AircraftAlts []map[string]bool
+	Wanted        map[string]map[string]bool // Airline to Aircraft to Urgent
+	AirlineNames  map[string]string
+	AircraftAlts  []map[string]bool
+	AircraftTypes map[string]string // ICAO designator to description, e.g. "ML2J"
+	TypeAliases   map[string]string // typeKey to ICAO designator
+
+	// From vPilot's ModelMatchingData.xml
+	ModelSpecs        map[string]ModelSpec // by title
+	AirlineCodes      map[string]bool
+	TypeCodes         map[string]bool // all ICAO designators vPilot knows
+	LiveriedTypeCodes map[string]bool // designators that come in airline liveries
+
+	typeNames map[string]string // typeKey of ICAO model names to designator
+	military  map[string]bool   // ICAO designators of military operators
 }
 
 func (d *Database) UnmarshalJSON(b []byte) error {
@@ -25,7 +36,7 @@ func (d *Database) UnmarshalJSON(b []byte) error {
 
 	for al, acs := range x {
 		for _, ac := range acs {
-			d.Add(APIStation{Callsign: al, Aircraft: ac})
+			d.add(al, ac, false)
 		}
 	}
 
@@ -42,34 +53,105 @@ func (d *Database) MarshalJSON() ([]byte, error) {
 	return json.Marshal(x)
 }
 
+// ModelSpec describes one of the models that vPilot knows about.
+type ModelSpec struct {
+	Title          string `xml:",attr"`
+	TypeCode       string `xml:",attr"`
+	CallsignPrefix string `xml:",attr"`
+}
+
+// ReadVPilotModelData reads vPilot's ModelMatchingData.xml.
 func (d *Database) ReadVPilotModelData(r io.Reader) error {
 	d.AircraftAlts = make([]map[string]bool, 0, 60)
 	var m struct {
-		XMLName          xml.Name `xml:"ModelMatchingData"`
+		XMLName    xml.Name `xml:"ModelMatchingData"`
+		ModelSpecs struct {
+			ModelSpec []ModelSpec
+		}
+		TypeCodeAliases struct {
+			TypeCodeAlias []struct {
+				Alias    string `xml:",attr"`
+				TypeCode string `xml:",attr"`
+			}
+		}
+		AirlineCodes struct {
+			String []string `xml:"string"`
+		}
+		TypeCodes struct {
+			String []string `xml:"string"`
+		}
 		SimilarTypeCodes struct {
 			String []string `xml:"string"`
 		}
+		LiveriedTypeCodes struct {
+			String []string `xml:"string"`
+		}
 	}
 
 	if err := xml.NewDecoder(r).Decode(&m); err != nil {
 		return err
 	}
 
+	d.ModelSpecs = make(map[string]ModelSpec, len(m.ModelSpecs.ModelSpec))
+	for _, spec := range m.ModelSpecs.ModelSpec {
+		d.ModelSpecs[spec.Title] = spec
+	}
+
+	if d.TypeAliases == nil {
+		d.TypeAliases = make(map[string]string, len(m.TypeCodeAliases.TypeCodeAlias))
+	}
+	for _, x := range m.TypeCodeAliases.TypeCodeAlias {
+		d.TypeAliases[typeKey(x.Alias)] = x.TypeCode
+	}
+
+	d.AirlineCodes = stringSet(m.AirlineCodes.String)
+	d.TypeCodes = stringSet(m.TypeCodes.String)
+	d.LiveriedTypeCodes = stringSet(m.LiveriedTypeCodes.String)
+
 	for _, s := range m.SimilarTypeCodes.String {
-		codes := strings.Fields(s)
-		if len(codes) < 2 {
+		d.addSimilarTypes(s)
+	}
+	return nil
+}
+
+// ReadSimilarTypes reads additional groups of similar aircraft types, one
+// group per line, in the same format as the SimilarTypeCodes in vPilot's
+// ModelMatchingData.xml: "A320 A20N A319". Lines starting with "#" are
+// ignored.
+func (d *Database) ReadSimilarTypes(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
 			continue
 		}
-		set := make(map[string]bool, len(codes))
-		for _, x := range codes {
-			if x == "CRJ2" {
-				set["CRJ200"] = true
-			}
-			set[x] = true
-		}
-		d.AircraftAlts = append(d.AircraftAlts, set)
+		d.addSimilarTypes(line)
 	}
-	return nil
+
+	return scanner.Err()
+}
+
+func (d *Database) addSimilarTypes(s string) {
+	codes := strings.Fields(s)
+	if len(codes) < 2 {
+		return
+	}
+	d.AircraftAlts = append(d.AircraftAlts, stringSet(codes))
+}
+
+// Liveried tells whether models of the aircraft type ac differ by airline.
+// For other types, such as a Cessna 172, vPilot doesn't match liveries. All
+// types are liveried if ModelMatchingData.xml hasn't been read.
+func (d *Database) Liveried(ac string) bool {
+	return len(d.LiveriedTypeCodes) == 0 || d.LiveriedTypeCodes[ac]
+}
+
+func stringSet(x []string) map[string]bool {
+	set := make(map[string]bool, len(x))
+	for _, s := range x {
+		set[s] = true
+	}
+	return set
 }
 
 func (d *Database) ReadEuroScopeICAO(r io.Reader) error {
@@ -84,8 +166,16 @@ func (d *Database) ReadEuroScopeICAO(r io.Reader) error {
 		switch len(fields) {
 		case 3:
 			d.AirlineNames[fields[0]] = fields[2]
+			if militaryPattern.MatchString(fields[1]) {
+				if d.military == nil {
+					d.military = make(map[string]bool)
+				}
+				d.military[fields[0]] = true
+			}
+		case 4:
+			d.addAircraftType(fields[0], fields[1], fields[2], fields[3])
 		default:
-			// Probably not an ICAO_Airlines file
+			// Probably not an ICAO_Airlines or ICAO_Aircraft file
 		}
 	}
 
@@ -95,34 +185,44 @@ func (d *Database) ReadEuroScopeICAO(r io.Reader) error {
 type APIStation struct {
 	Callsign string
 	Aircraft string
+	Remarks  string // item 18, see ParseAircraft; recorded in snapshots
 
 	Destination string
 	Origin      string
 }
 
 func (d *Database) Add(x APIStation) {
-	if len(x.Aircraft) < 3 {
+	if d.designator(x.Aircraft) == "" {
 		return
 	}
 
-	airline := parseCallsign(x.Callsign)
-	if airline == "" {
+	cs := d.ClassifyCallsign(x.Callsign)
+	switch cs.Kind {
+	case AirlineCallsign, MilitaryCallsign, RegistrationCallsign:
+	default:
+		// Nothing we could install models for.
 		return
 	}
 
+	urgent := x.Origin == "EDDT" || x.Destination == "EDDT"
+	d.add(cs.Prefix, x.Aircraft, urgent)
+}
+
+// add adds an aircraft to the wanted aircraft of an airline or registration
+// prefix.
+func (d *Database) add(prefix, aircraft string, urgent bool) {
 	switch {
 	case d.Wanted == nil:
 		d.Wanted = make(map[string]map[string]bool)
 		fallthrough
-	case d.Wanted[airline] == nil:
-		d.Wanted[airline] = make(map[string]bool)
-	case d.Wanted[airline][x.Aircraft]:
+	case d.Wanted[prefix] == nil:
+		d.Wanted[prefix] = make(map[string]bool)
+	case d.Wanted[prefix][aircraft]:
 		// already present and Urgent
 		return
 	}
 
-	urgent := x.Origin == "EDDT" || x.Destination == "EDDT"
-	d.Wanted[airline][x.Aircraft] = urgent
+	d.Wanted[prefix][aircraft] = urgent
 }
 
 func (d *Database) All() map[string]map[string]bool {
@@ -132,7 +232,7 @@ func (d *Database) All() map[string]map[string]bool {
 		x[al] = make(map[string]bool, len(acs))
 		seen := make(map[string]bool, len(acs))
 		for ac, urgent := range acs {
-			ac = parseAircraft(ac)
+			ac = d.designator(ac)
 			switch {
 			case ac == "":
 			case seen[ac]:
@@ -147,15 +247,32 @@ func (d *Database) All() map[string]map[string]bool {
 }
 
 var callsignPattern = regexp.MustCompile(`^[A-Z]{3}`)
-var aircraftPattern = regexp.MustCompile(`^(?:[MHT]/)?([A-Z][A-Z0-9]{1,3})(?:/[A-Z])?$`)
 
 func parseCallsign(callsign string) (airlineCode string) {
 	return callsignPattern.FindString(callsign)
 }
 
-func parseAircraft(s string) string {
-	if x := aircraftPattern.FindStringSubmatch(s); len(x) > 1 {
-		return x[1]
+// designator returns the ICAO type designator for the aircraft field of a
+// flight plan, or the empty string if it is invalid. Models are matched by
+// type only, so the other fields are ignored.
+func (d *Database) designator(field string) string {
+	a, err := ParseAircraft(field, "")
+	if err != nil {
+		return ""
+	}
+	if _, ok := d.AircraftTypes[a.Type]; ok || len(d.AircraftTypes) == 0 {
+		return a.Type
+	}
+	if d.TypeCodes[a.Type] {
+		// Newer than EuroScope's ICAO_Aircraft.txt, such as the B38M.
+		return a.Type
+	}
+	if t := d.NormalizeType(a.Type); t != "" {
+		return t
+	}
+	if len(a.Type) <= 4 {
+		// Possibly a new designator that EuroScope doesn't know yet.
+		return a.Type
 	}
 	return ""
 }
@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"compress/gzip"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestParseCallsign(t *testing.T) {
 	cases := []struct {
@@ -44,99 +50,117 @@ func TestParseCallsign(t *testing.T) {
 	}
 }
 
-func TestParseAircraft(t *testing.T) {
-	cases := []struct {
+func TestReadVPilotModelData(t *testing.T) {
+	f, err := os.Open("ModelMatchingData.xml.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &Database{}
+	if err := db.ReadVPilotModelData(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := db.ModelSpecs["JFAi_A300_MON"], (ModelSpec{"JFAi_A300_MON", "A306", "MON"}); got != want {
+		t.Errorf("ModelSpecs[JFAi_A300_MON] == %+v, want %+v", got, want)
+	}
+	if got, want := db.TypeAliases["707"], "B701"; got != want {
+		t.Errorf("TypeAliases[707] == %q, want %q", got, want)
+	}
+	if got, want := db.NormalizeType("TYPH"), "EUFI"; got != want {
+		t.Errorf("NormalizeType(TYPH) == %q, want %q", got, want)
+	}
+	if !db.AirlineCodes["DLH"] {
+		t.Error("AirlineCodes[DLH] not set")
+	}
+	if !db.TypeCodes["CONC"] {
+		t.Error("TypeCodes[CONC] not set")
+	}
+	if !db.LiveriedTypeCodes["A318"] {
+		t.Error("LiveriedTypeCodes[A318] not set")
+	}
+
+	n := len(db.AircraftAlts)
+	if n == 0 {
+		t.Fatal("no SimilarTypeCodes")
+	}
+
+	err = db.ReadSimilarTypes(strings.NewReader("# comment\n\nA320 A20N\nB738\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(db.AircraftAlts); got != n+1 {
+		t.Fatalf("len(AircraftAlts) == %d after ReadSimilarTypes, want %d", got, n+1)
+	}
+	if alts := db.AircraftAlts[n]; !alts["A320"] || !alts["A20N"] {
+		t.Errorf("AircraftAlts[%d] == %v, want A320 and A20N", n, alts)
+	}
+}
+
+func TestLiveried(t *testing.T) {
+	testCases := []struct {
+		name     string
+		liveried map[string]bool
+		ac       string
+		want     bool
+	}{
+		{"liveried", map[string]bool{"A320": true}, "A320", true},
+		{"not liveried", map[string]bool{"A320": true}, "C172", false},
+		{"no data", nil, "C172", true},
+	}
+
+	for _, tc := range testCases {
+		db := &Database{LiveriedTypeCodes: tc.liveried}
+		if got := db.Liveried(tc.ac); got != tc.want {
+			t.Errorf("%s: Liveried(%q) == %v, want %v", tc.name, tc.ac, got, tc.want)
+		}
+	}
+}
+
+func TestDesignator(t *testing.T) {
+	db := &Database{TypeCodes: map[string]bool{"B38M": true}}
+	db.ReadEuroScopeICAO(strings.NewReader("A320\tML2J\tAIRBUS\tA-320\n"))
+
+	testCases := []struct {
 		given, want string
 	}{
-		// http://www.flugzeuginfo.net/table_accodes_en.php
-		{"738/L", ""},
-		{"772/A", ""},
-		{"A318", "A318"},
-		{"A318/L", "A318"},
-		{"A319", "A319"},
-		{"A319/L", "A319"},
-		{"A320", "A320"},
-		{"A320/G", "A320"},
-		{"A320/L", "A320"},
-		{"A320/W", "A320"},
-		{"A321", "A321"},
-		{"A321/X", "A321"},
-		{"B60T", "B60T"},
-		{"B733/L", "B733"},
-		{"B737", "B737"},
-		{"B737/L", "B737"},
-		{"B738", "B738"},
-		{"B738/F", "B738"},
-		{"B738/L", "B738"},
-		{"B738/O", "B738"},
-		{"B738/Q", "B738"},
-		{"B738/R", "B738"},
-		{"B738/S", "B738"},
-		{"B738/W", "B738"},
-		{"B738/X", "B738"},
-		{"B739", "B739"},
-		{"B739/L", "B739"},
-		{"B744", "B744"},
-		{"B744/H", "B744"},
-		{"B747/L", "B747"},
-		{"B752/L", "B752"},
-		{"B763/L", "B763"},
-		{"B772", "B772"},
-		{"B773", "B773"},
-		{"B777", "B777"},
-		{"B777F", ""},
-		{"B77F", "B77F"},
-		{"B77W", "B77W"},
-		{"B77W/L", "B77W"},
-		{"C172", "C172"},
-		{"CRJ7/L", "CRJ7"},
-		{"CRJ9/F", "CRJ9"},
-		{"CRJ9/L", "CRJ9"},
-		{"DA20", "DA20"},
-		{"DH8D", "DH8D"},
-		{"DH8D/G", "DH8D"},
-		{"DH8D/L", "DH8D"},
-		{"GL5T", "GL5T"},
-		{"H/738", ""},
-		{"H/A319", "A319"},
-		{"H/A320", "A320"},
-		{"H/A332/L", "A332"},
-		{"H/A332/X", "A332"},
-		{"H/A333", "A333"},
-		{"H/A359/L", "A359"},
-		{"H/B738", "B738"},
-		{"H/B738/M", "B738"},
-		{"H/B738/Q", "B738"},
-		{"H/B744/", ""},
-		{"H/B744", "B744"},
-		{"H/B744/L", "B744"},
-		{"H/B744/S", "B744"},
-		{"H/B748/L", "B748"},
-		{"H/B77F", "B77F"},
-		{"H/B77L", "B77L"},
-		{"H/B77L/L", "B77L"},
-		{"H/B77L/X", "B77L"},
-		{"H/B77W", "B77W"},
-		{"H/B77W/H", "B77W"},
-		{"H/B77W/L", "B77W"},
-		{"H/B789/L", "B789"},
-		{"H/H/L", ""},
-		{"JS41/G", "JS41"},
-		{"M/A320/E", "A320"},
-		{"M/A321/L", "A321"},
-		{"MD82/F", "MD82"},
-		{"M/CRJ2/Q", "CRJ2"},
+		{"A320/M-SDE2E3FGHIRWY/LB1", "A320"},
+		{"H/A320/L", "A320"},
+		{"B38M/M-SDFG", "B38M"}, // known to vPilot only
+		{"ZZZZ", "ZZZZ"},        // possibly new
+		{"B738/X-SDFG", ""},
+		{"??", ""},
 	}
 
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.given, func(t *testing.T) {
-			t.Parallel()
+	for _, tc := range testCases {
+		if got := db.designator(tc.given); got != tc.want {
+			t.Errorf("designator(%q) == %q, want %q", tc.given, got, tc.want)
+		}
+	}
+}
 
-			if want, got := tc.want, parseAircraft(tc.given); want != got {
-				t.Logf("%q %+v", tc.given, aircraftPattern.FindStringSubmatch(tc.given))
-				t.Errorf("parseAircraft(%q) == %q, want %q", tc.given, got, want)
-			}
-		})
+func TestAdd(t *testing.T) {
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("DLH\tLufthansa - Germany\tLUFTHANSA\n"))
+
+	for _, x := range []APIStation{
+		{Callsign: "DLH1", Aircraft: "A320/M-SDE2E3FGHIRWY/LB1", Remarks: "PBN/A1B1C1D1 /V/"},
+		{Callsign: "DLH2", Aircraft: "H/B744/L", Origin: "EDDT"},
+		{Callsign: "DLH3", Aircraft: "B738/X-SDFG"}, // invalid wake category
+		{Callsign: "DLH4", Aircraft: "??"},
+	} {
+		db.Add(x)
+	}
+
+	want := map[string]map[string]bool{
+		"DLH": {"A320/M-SDE2E3FGHIRWY/LB1": false, "H/B744/L": true},
+	}
+	if !reflect.DeepEqual(db.Wanted, want) {
+		t.Errorf("Wanted == %v, want %v", db.Wanted, want)
 	}
 }
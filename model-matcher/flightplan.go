@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// FlightPlanAircraft is the parsed aircraft field of a flight plan. Both the
+// legacy FAA format ("H/B744/L") and the ICAO 2012 format
+// ("B744/H-SDE2E3FGHIJ2J3J4J5M1RWXYZ/LB1D1") are supported.
+type FlightPlanAircraft struct {
+	Type string // ICAO type designator, as filed
+
+	// Wake is the ICAO wake turbulence category (L, M, H, or J for the
+	// A380), or empty if unknown.
+	Wake string
+
+	TCAS bool // legacy "T/" and "B/" prefixes
+
+	Equipment    string // ICAO item 10a, e.g. "SDE2E3FGHIJ2RWXY"
+	Surveillance string // ICAO item 10b, e.g. "LB1"
+	FAASuffix    string // legacy equipment suffix, e.g. "L"
+	PBN          string // PBN/ from item 18, e.g. "A1B1C1D1"
+}
+
+var (
+	typeDesignatorPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,4}$`)
+	pbnPattern            = regexp.MustCompile(`(?:^|\s)PBN/([A-Z0-9]+)`)
+)
+
+// ParseAircraft parses the aircraft field of a flight plan. otherInfo is the
+// content of item 18 (the remarks, on VATSIM); it may be empty.
+//
+// Type designators are not validated beyond their syntax; "B777F" is
+// accepted, for instance. Use Database.NormalizeType to clean them up.
+func ParseAircraft(s, otherInfo string) (FlightPlanAircraft, error) {
+	var a FlightPlanAircraft
+
+	s = strings.ToUpper(strings.TrimSpace(s))
+	parts := strings.Split(s, "/")
+
+	// Legacy prefix: "H/B744", "T/A320", "B/B744"
+	if len(parts) > 1 && len(parts[0]) == 1 {
+		switch parts[0] {
+		case "H":
+			a.Wake = "H"
+		case "J":
+			a.Wake = "J"
+		case "M", "L":
+			a.Wake = parts[0]
+		case "T":
+			a.TCAS = true
+		case "B":
+			a.Wake = "H"
+			a.TCAS = true
+		default:
+			return a, errors.New("invalid aircraft prefix: " + s)
+		}
+		parts = parts[1:]
+	}
+
+	if !typeDesignatorPattern.MatchString(parts[0]) {
+		return a, errors.New("invalid aircraft type: " + s)
+	}
+	a.Type = parts[0]
+	parts = parts[1:]
+
+	switch {
+	case len(parts) == 0:
+	case strings.Contains(parts[0], "-"):
+		// ICAO: "M-SDE2E3FGHIJ1RWXY/LB1"
+		if len(parts) > 2 {
+			return a, errors.New("invalid aircraft equipment: " + s)
+		}
+		i := strings.IndexByte(parts[0], '-')
+		switch wake := parts[0][:i]; wake {
+		case "L", "M", "H", "J":
+			a.Wake = wake
+		default:
+			return a, errors.New("invalid wake turbulence category: " + s)
+		}
+		a.Equipment = parts[0][i+1:]
+		if len(parts) == 2 {
+			a.Surveillance = parts[1]
+		}
+	case len(parts) > 1:
+		return a, errors.New("invalid aircraft equipment: " + s)
+	case len(parts[0]) <= 1:
+		// FAA: "L", or nothing at all
+		a.FAASuffix = parts[0]
+	default:
+		return a, errors.New("invalid aircraft equipment: " + s)
+	}
+
+	if m := pbnPattern.FindStringSubmatch(strings.ToUpper(otherInfo)); m != nil {
+		a.PBN = m[1]
+	}
+
+	return a, nil
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A RuleFormat reads and writes rule sets in the format of a particular pilot
+// client. Each rule set is stored in its own file.
+type RuleFormat interface {
+	File(ruleSet string) string // where the client expects the rule set
+	CSL() bool                  // whether the client uses X-Plane CSL models
+
+	// Existing returns the names of the rule sets that have a file
+	// already.
+	Existing() ([]string, error)
+
+	Read(r io.Reader) (*RuleSet, error)
+	Write(w io.Writer, ruleSet string, rs *RuleSet) error
+}
+
+// ruleFormats creates the rule set formats by name. CSL formats copy the
+// installed models from the index into a package in the first CSL directory.
+var ruleFormats = map[string]func(idx Index, cslDirs []string) (RuleFormat, error){
+	"vpilot": func(Index, []string) (RuleFormat, error) { return vrmFormat{}, nil },
+	"swift":  func(Index, []string) (RuleFormat, error) { return swiftFormat{}, nil },
+	"xpilot": newCSLFormat,
+}
+
+// vrmFormat is vPilot's ModelMatchRuleSet XML format.
+type vrmFormat struct{}
+
+func (vrmFormat) File(ruleSet string) string {
+	return filepath.Join("vPilot Files/Model Matching Rule Sets", ruleSet+".vrm")
+}
+
+func (vrmFormat) CSL() bool { return false }
+
+func (f vrmFormat) Existing() ([]string, error) {
+	fnames, err := filepath.Glob(f.File("*"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(fnames))
+	for i, fname := range fnames {
+		names[i] = strings.TrimSuffix(filepath.Base(fname), ".vrm")
+	}
+	return names, nil
+}
+
+func (vrmFormat) Read(r io.Reader) (*RuleSet, error) {
+	return ReadRuleSet(r)
+}
+
+func (vrmFormat) Write(w io.Writer, _ string, rs *RuleSet) error {
+	b, err := xml.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append([]byte(xml.Header), b...))
+	return err
+}
+
+// swiftFormat is a model set in swift's JSON format, to be loaded in swift's
+// model set editor, for swift on FSX and P3D. swift matches a model by its
+// type and airline, so each title of a rule becomes a model of the rule's type
+// and airline. swift has no notion of substitutes or hand-written rules; the
+// flags are kept in the description, which is what Read uses.
+type swiftFormat struct{}
+
+type swiftModel struct {
+	ModelString  string `json:"modelString"`
+	Description  string `json:"description"`
+	AircraftIcao struct {
+		Designator string `json:"designator"`
+	} `json:"aircraftIcao"`
+	Livery struct {
+		Airline struct {
+			Designator string `json:"designator"`
+		} `json:"airline"`
+	} `json:"livery"`
+}
+
+type swiftModelSet struct {
+	Models []swiftModel `json:"containerbase"`
+}
+
+// swiftDescriptionPrefix starts the descriptions of the models that
+// swiftFormat writes, followed by the rule's flags.
+const swiftDescriptionPrefix = "model-matcher"
+
+func (swiftFormat) File(ruleSet string) string {
+	return filepath.Join("swift Files/Model Sets", ruleSet+".json")
+}
+
+func (swiftFormat) CSL() bool { return false }
+
+func (f swiftFormat) Existing() ([]string, error) {
+	fnames, err := filepath.Glob(f.File("*"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(fnames))
+	for i, fname := range fnames {
+		names[i] = strings.TrimSuffix(filepath.Base(fname), ".json")
+	}
+	return names, nil
+}
+
+func (swiftFormat) Read(r io.Reader) (*RuleSet, error) {
+	var set swiftModelSet
+	if err := json.NewDecoder(r).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	// Join the titles of consecutive models that belong to the same rule
+	// again.
+	rs := &RuleSet{}
+	for _, m := range set.Models {
+		flags := strings.Fields(strings.TrimPrefix(m.Description, swiftDescriptionPrefix))
+		r := Rule{
+			AL:    m.Livery.Airline.Designator,
+			AC:    m.AircraftIcao.Designator,
+			Model: m.ModelString,
+		}
+		for _, flag := range flags {
+			switch flag {
+			case "substitute":
+				r.Substitute = true
+			case "locked":
+				r.Locked = true
+			}
+		}
+		if n := len(rs.Rules); n > 0 && rs.Rules[n-1].key() == r.key() {
+			rs.Rules[n-1].Model += "//" + r.Model
+			continue
+		}
+		rs.Rules = append(rs.Rules, r)
+	}
+
+	return rs, nil
+}
+
+func (swiftFormat) Write(w io.Writer, _ string, rs *RuleSet) error {
+	set := swiftModelSet{Models: []swiftModel{}}
+	for _, r := range rs.Rules {
+		desc := swiftDescriptionPrefix
+		if r.Substitute {
+			desc += " substitute"
+		}
+		if r.Locked {
+			desc += " locked"
+		}
+
+		for _, title := range r.Titles() {
+			var m swiftModel
+			m.ModelString = title
+			m.Description = desc
+			m.AircraftIcao.Designator = r.AC
+			m.Livery.Airline.Designator = r.AL
+			set.Models = append(set.Models, m)
+		}
+	}
+
+	b, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// cslPackagePrefix starts the names of the CSL packages that cslFormat
+// writes. They are skipped when scanning for installed models.
+const cslPackagePrefix = "ModelMatcher_"
+
+// cslFormat writes a rule set as a CSL package, which xPilot and other
+// clients based on libxplanemp load like any other. For each title of a rule,
+// the package contains a copy of the installed model, declaring the rule's
+// type and airline instead of the original ones. The OBJ8 files and textures
+// are referenced, not copied, so the package depends on the original ones.
+//
+// Each model is preceded by a comment with the rule, which is what Read
+// returns:
+//
+//	# DLH A321 substitute: BB_A320/A320_DLH
+type cslFormat struct {
+	dir   string
+	index Index
+}
+
+func newCSLFormat(idx Index, cslDirs []string) (RuleFormat, error) {
+	if len(cslDirs) == 0 {
+		return nil, errors.New("CSL rule sets need a CSL directory; see -csl")
+	}
+	return cslFormat{cslDirs[0], idx}, nil
+}
+
+func (f cslFormat) File(ruleSet string) string {
+	return filepath.Join(f.dir, cslExportName(ruleSet), "xsb_aircraft.txt")
+}
+
+func (cslFormat) CSL() bool { return true }
+
+// Existing returns the package names without prefix, which File maps to the
+// same packages again.
+func (f cslFormat) Existing() ([]string, error) {
+	fnames, err := filepath.Glob(filepath.Join(f.dir, cslPackagePrefix+"*", "xsb_aircraft.txt"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(fnames))
+	for i, fname := range fnames {
+		names[i] = strings.TrimPrefix(filepath.Base(filepath.Dir(fname)), cslPackagePrefix)
+	}
+	return names, nil
+}
+
+// cslExportName returns the package name for a rule set, such as
+// "ModelMatcher_Registration_DE".
+func cslExportName(ruleSet string) string {
+	return cslPackagePrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, ruleSet)
+}
+
+var cslRulePattern = regexp.MustCompile(`^# (\S+) (\S+)((?: substitute| locked)*): (.+)$`)
+
+func (cslFormat) Read(r io.Reader) (*RuleSet, error) {
+	rs := &RuleSet{}
+
+	// Join the titles of consecutive models that belong to the same rule
+	// again.
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := cslRulePattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		r := Rule{
+			AL:         m[1],
+			AC:         m[2],
+			Model:      m[4],
+			Substitute: strings.Contains(m[3], "substitute"),
+			Locked:     strings.Contains(m[3], "locked"),
+		}
+		if n := len(rs.Rules); n > 0 && rs.Rules[n-1].key() == r.key() {
+			rs.Rules[n-1].Model += "//" + r.Model
+			continue
+		}
+		rs.Rules = append(rs.Rules, r)
+	}
+
+	return rs, scanner.Err()
+}
+
+// cslDependencyPattern matches the package names in the paths of OBJ8 and
+// other lines, such as "__BB_A320" in "__BB_A320:A320/A320.obj".
+var cslDependencyPattern = regexp.MustCompile(`(?:^|\s)(__[^\s:/]+)[:/]`)
+
+func (f cslFormat) Write(w io.Writer, ruleSet string, rs *RuleSet) error {
+	models := make(map[string]InstalledModel)
+	for _, e := range f.index {
+		for _, m := range e.Models {
+			if m.CSL {
+				models[m.Title] = m
+			}
+		}
+	}
+
+	var body bytes.Buffer
+	deps := make(map[string]bool)
+	for _, r := range rs.Rules {
+		flags := ""
+		if r.Substitute {
+			flags += " substitute"
+		}
+		if r.Locked {
+			flags += " locked"
+		}
+
+		for i, title := range r.Titles() {
+			fmt.Fprintf(&body, "\n# %s %s%s: %s\n", r.AL, r.AC, flags, title)
+
+			m, ok := models[title]
+			if !ok || len(m.CSLLines) == 0 {
+				// Keep the rule, but there is nothing to copy.
+				continue
+			}
+			for j, line := range m.CSLLines {
+				if j == 0 && strings.HasPrefix(line, "OBJ8_AIRCRAFT") {
+					line = fmt.Sprintf("OBJ8_AIRCRAFT %s_%s_%d", r.AL, r.AC, i+1)
+				}
+				for _, dep := range cslDependencyPattern.FindAllStringSubmatch(line, -1) {
+					deps[dep[1]] = true
+				}
+				fmt.Fprintln(&body, line)
+			}
+			fmt.Fprintf(&body, "ICAO %s\n", r.AC)
+			fmt.Fprintf(&body, "AIRLINE %s %s\n", r.AC, r.AL)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Generated by model-matcher; changes are overwritten.")
+	fmt.Fprintf(bw, "EXPORT_NAME __%s\n", cslExportName(ruleSet))
+	for _, dep := range sortedKeys(deps) {
+		fmt.Fprintf(bw, "DEPENDENCY %s\n", dep)
+	}
+	body.WriteTo(bw)
+	return bw.Flush()
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuleFormats(t *testing.T) {
+	rs := &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "A320", Model: "WoA Lufthansa A320 1//WoA Lufthansa A320 2"},
+		{AL: "DLH", AC: "A321", Model: "WoA Lufthansa A320 1", Substitute: true},
+		{AL: "DLH", AC: "B744", Model: "Hand Written", Locked: true},
+	}}
+
+	for name, newFormat := range ruleFormats {
+		name, newFormat := name, newFormat
+		t.Run(name, func(t *testing.T) {
+			format, err := newFormat(Index{}, []string{"CSL"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := format.Write(&buf, "Lufthansa", rs); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := format.Read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got.Rules {
+				got.Rules[i].XMLName.Local = ""
+			}
+			if !reflect.DeepEqual(got.Rules, rs.Rules) {
+				t.Errorf("%+v != %+v", got.Rules, rs.Rules)
+			}
+		})
+	}
+}
+
+func TestSwiftFormat(t *testing.T) {
+	rs := &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "A320", Model: "WoA Lufthansa A320 1//WoA Lufthansa A320 2"},
+		{AL: "DLH", AC: "A321", Model: "WoA Lufthansa A320 1", Substitute: true, Locked: true},
+	}}
+
+	var buf bytes.Buffer
+	if err := (swiftFormat{}).Write(&buf, "Lufthansa", rs); err != nil {
+		t.Fatal(err)
+	}
+
+	var set swiftModelSet
+	if err := json.Unmarshal(buf.Bytes(), &set); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range set.Models {
+		got = append(got, fmt.Sprintf("%s %s %s: %s", m.Livery.Airline.Designator, m.AircraftIcao.Designator, m.ModelString, m.Description))
+	}
+	want := []string{
+		"DLH A320 WoA Lufthansa A320 1: model-matcher",
+		"DLH A320 WoA Lufthansa A320 2: model-matcher",
+		"DLH A321 WoA Lufthansa A320 1: model-matcher substitute locked",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("models ==\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	read, err := (swiftFormat{}).Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Rules, rs.Rules) {
+		t.Errorf("Read() == %+v, want %+v", read.Rules, rs.Rules)
+	}
+}
+
+func TestCSLFormat(t *testing.T) {
+	idx := Index{
+		"CSL/BB_A320/xsb_aircraft.txt": {Models: []InstalledModel{{
+			Title: "BB_A320/A320_DLH",
+			CSL:   true,
+			CSLLines: []string{
+				"OBJ8_AIRCRAFT A320_DLH",
+				"OBJ8 SOLID YES __BB_A320:A320/A320.obj DLH.png",
+			},
+		}}},
+	}
+	format, err := newCSLFormat(idx, []string{"CSL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := format.File("Registration DE"), "CSL/ModelMatcher_Registration_DE/xsb_aircraft.txt"; got != want {
+		t.Errorf("File() == %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	err = format.Write(&buf, "Lufthansa", &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "A321", Model: "BB_A320/A320_DLH//Not Installed", Substitute: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"# Generated by model-matcher; changes are overwritten.",
+		"EXPORT_NAME __ModelMatcher_Lufthansa",
+		"DEPENDENCY __BB_A320",
+		"",
+		"# DLH A321 substitute: BB_A320/A320_DLH",
+		"OBJ8_AIRCRAFT DLH_A321_1",
+		"OBJ8 SOLID YES __BB_A320:A320/A320.obj DLH.png",
+		"ICAO A321",
+		"AIRLINE A321 DLH",
+		"",
+		"# DLH A321 substitute: Not Installed",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("Write() ==\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := newCSLFormat(idx, nil); err == nil {
+		t.Error("newCSLFormat without CSL directory: no error")
+	}
+}
+
+func TestExistingRuleSets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for name, newFormat := range ruleFormats {
+		name, newFormat := name, newFormat
+		t.Run(name, func(t *testing.T) {
+			format, err := newFormat(Index{}, []string{"CSL"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ruleSets := []string{"Lufthansa", "Registration DE"}
+			want := make(map[string]bool)
+			for _, rs := range ruleSets {
+				fname := format.File(rs)
+				if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(fname, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+				want[fname] = true
+			}
+
+			// The names may differ, but must refer to the same files.
+			names, err := format.Existing()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]bool)
+			for _, name := range names {
+				got[format.File(name)] = true
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("files of Existing() == %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// indexVersion must be incremented whenever the parsed representation of
+// aircraft.cfg files changes, so that stale caches are discarded.
+const indexVersion = 6
+
+// Index maps the paths of aircraft.cfg files to the models they define.
+type Index map[string]indexEntry
+
+type indexEntry struct {
+	fileState
+	Models []InstalledModel
+}
+
+// LoadIndex reads an index that has been written by Save. It returns an empty
+// index if the file doesn't exist or has been written by an incompatible
+// version.
+func LoadIndex(fname string) (Index, error) {
+	idx := make(Index)
+
+	b, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return idx, err
+	}
+
+	var x struct {
+		Version int
+		Entries Index
+	}
+	if err := json.Unmarshal(b, &x); err != nil {
+		return idx, err
+	}
+	if x.Version != indexVersion || x.Entries == nil {
+		return idx, nil
+	}
+
+	return x.Entries, nil
+}
+
+// Save writes the index to fname, so that unchanged files don't have to be
+// parsed again in the next run.
+func (idx Index) Save(fname string) error {
+	b, err := json.Marshal(struct {
+		Version int
+		Entries Index
+	}{indexVersion, idx})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(fname, b, 0644)
+}
+
+// ModelPaths returns the paths of all aircraft.cfg files below root.
+func ModelPaths(root string) ([]string, error) {
+	return filepath.Glob(filepath.Join(root, "SimObjects/Airplanes/*/aircraft.cfg"))
+}
+
+// Scan brings the index up to date with all aircraft.cfg files below root,
+// and all CSL packages in cslDirs. If ctx is canceled, Scan stops early and
+// returns ctx.Err(); the index is consistent but not complete then.
+func (idx Index) Scan(ctx context.Context, root string, cslDirs []string) error {
+	paths, err := ModelPaths(root)
+	if err != nil {
+		return err
+	}
+	csl, err := CSLPaths(cslDirs)
+	if err != nil {
+		return err
+	}
+	paths = append(paths, csl...)
+
+	found := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		found[path] = true
+	}
+	for path := range idx {
+		if !found[path] {
+			delete(idx, path)
+		}
+	}
+
+	return idx.Update(ctx, paths...)
+}
+
+// parseWorkers is the number of aircraft.cfg files parsed concurrently. Most
+// of the time is spent waiting for the file system, especially on Windows
+// drives mounted into WSL.
+var parseWorkers = 4 * runtime.NumCPU()
+
+// Update re-reads the given aircraft.cfg or xsb_aircraft.txt files if their
+// size or modification time changed, or if folders were added to or removed
+// from their directory. Files that don't exist anymore are removed from the
+// index. If ctx is canceled, the remaining files are skipped and ctx.Err() is
+// returned.
+func (idx Index) Update(ctx context.Context, paths ...string) error {
+	type job struct {
+		path string
+		old  fileState
+	}
+	type result struct {
+		path    string
+		entry   indexEntry
+		deleted bool
+	}
+
+	jobs := make(chan job)
+	results := make(chan result)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < parseWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				state, err := statFile(j.path)
+				if err != nil {
+					if !os.IsNotExist(err) {
+						slog.Warn("Cannot stat model", "err", err)
+					}
+					results <- result{path: j.path, deleted: true}
+					continue
+				}
+
+				if state.Equal(j.old) {
+					continue
+				}
+
+				parse := AircraftConfig
+				if filepath.Base(j.path) == "xsb_aircraft.txt" {
+					parse = CSLPackage
+				}
+				models, err := parse(j.path)
+				switch {
+				case os.IsNotExist(err):
+					results <- result{path: j.path, deleted: true}
+				case err != nil:
+					slog.Warn("Cannot read model", "file", j.path, "err", err)
+					results <- result{path: j.path, deleted: true}
+				default:
+					results <- result{path: j.path, entry: indexEntry{state, models}}
+				}
+			}
+		}()
+	}
+
+	go func() {
+	feed:
+		for _, path := range paths {
+			if ctx.Err() != nil {
+				break
+			}
+			select {
+			case jobs <- job{path, idx[path].fileState}:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if r.deleted {
+			delete(idx, r.path)
+		} else {
+			idx[r.path] = r.entry
+		}
+	}
+	return ctx.Err()
+}
+
+// ByAirline returns the titles of all installed FSX models, or all CSL models,
+// grouped by airline name and ICAO type designator.
+func (idx Index) ByAirline(db *Database, csl bool) map[string]map[string][]string {
+	x := make(map[string]map[string][]string) // Airline to Aircraft to Titles
+
+	for path, e := range idx {
+		for _, m := range e.Models {
+			if m.Problem != "" || m.CSL != csl {
+				continue
+			}
+			ac := db.InferType(path, m)
+			if ac == "" {
+				continue
+			}
+			al := m.AirlineName
+			if m.AirlineCode != "" {
+				// Not a model without airline, for registration
+				// callsigns, just because the code is unknown.
+				if al = db.AirlineNames[m.AirlineCode]; al == "" {
+					continue
+				}
+			}
+			if spec, ok := db.ModelSpecs[m.Title]; ok && db.AirlineNames[spec.CallsignPrefix] != "" {
+				al = db.AirlineNames[spec.CallsignPrefix]
+			}
+			if x[al] == nil {
+				x[al] = make(map[string][]string)
+			}
+			x[al][ac] = append(x[al][ac], m.Title)
+		}
+	}
+
+	// Keep the order stable, so that rule sets don't change between runs.
+	for _, acs := range x {
+		for _, titles := range acs {
+			sort.Strings(titles)
+		}
+	}
+
+	return x
+}
+
+// Broken returns a description of each installed model that cannot be used,
+// for instance because its texture folder is missing.
+func (idx Index) Broken() []string {
+	var x []string
+	for path, e := range idx {
+		for _, m := range e.Models {
+			if m.Problem != "" {
+				x = append(x, fmt.Sprintf("%s: %s: %s", path, m.Title, m.Problem))
+			}
+		}
+	}
+	sort.Strings(x)
+	return x
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAircraftConfig = `[fltsim.0]
+title=WoA Lufthansa A320
+atc_airline=LUFTHANSA
+
+[General]
+atc_model=A320
+`
+
+func TestIndex(t *testing.T) {
+	root, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, "SimObjects/Airplanes/WoA_DLH_A320")
+	for _, d := range []string{"model", "texture"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "aircraft.cfg")
+	if err := ioutil.WriteFile(path, []byte(testAircraftConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("A320\tML2J\tAIRBUS\tA-320\n"))
+
+	// Interrupted before anything is parsed.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	idx := make(Index)
+	if err := idx.Scan(canceled, root, nil); err != context.Canceled {
+		t.Errorf("Scan() with canceled context == %v, want %v", err, context.Canceled)
+	}
+	if len(idx) != 0 {
+		t.Errorf("Scan() with canceled context indexed %d files", len(idx))
+	}
+
+	if err := idx.Scan(context.Background(), root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string][]string{
+		"LUFTHANSA": {"A320": {"WoA Lufthansa A320"}},
+	}
+	if got := idx.ByAirline(db, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("%+v != %+v", got, want)
+	}
+
+	cache := filepath.Join(root, "cache/index.json")
+	if err := idx.Save(cache); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = LoadIndex(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.ByAirline(db, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("after LoadIndex: %+v != %+v", got, want)
+	}
+
+	// Unchanged files are not parsed again.
+	e := idx[path]
+	e.Models[0].Title = "cached"
+	idx[path] = e
+	idx.Update(context.Background(), path)
+	if got := idx[path].Models[0].Title; got != "cached" {
+		t.Errorf("unchanged file parsed again; title == %q", got)
+	}
+
+	// Changed files are.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx.Update(context.Background(), path)
+	if got := idx.ByAirline(db, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("after Chtimes: %+v != %+v", got, want)
+	}
+
+	// A texture folder that is installed later repairs a broken model.
+	if err := os.Rename(filepath.Join(dir, "texture"), filepath.Join(dir, "texture.old")); err != nil {
+		t.Fatal(err)
+	}
+	idx.Update(context.Background(), path)
+	if got := idx.Broken(); len(got) != 1 {
+		t.Errorf("Broken() == %v, want the model without texture folder", got)
+	}
+	if err := os.Rename(filepath.Join(dir, "texture.old"), filepath.Join(dir, "texture")); err != nil {
+		t.Fatal(err)
+	}
+	// In case both renames happen within the resolution of the clock.
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(dir, later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx.Update(context.Background(), path)
+	if got := idx.Broken(); len(got) != 0 {
+		t.Errorf("Broken() == %v after installing the texture folder", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	idx.Update(context.Background(), path)
+	if len(idx) != 0 {
+		t.Errorf("removed file still in index: %+v", idx)
+	}
+}
+
+func TestByAirlineCSL(t *testing.T) {
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("DLH\tLufthansa - Germany\tLUFTHANSA\nA320\tML2J\tAIRBUS\tA-320\n"))
+
+	idx := Index{
+		"SimObjects/Airplanes/WoA_DLH_A320/aircraft.cfg": {Models: []InstalledModel{
+			{Title: "WoA Lufthansa A320", AirlineName: "LUFTHANSA", Model: "A320"},
+		}},
+		"CSL/BB_A320/xsb_aircraft.txt": {Models: []InstalledModel{
+			{Title: "BB_A320/A320_DLH", AirlineCode: "DLH", Model: "A320", CSL: true},
+			{Title: "BB_A320/A320_XYZ", AirlineCode: "XYZ", Model: "A320", CSL: true},
+			{Title: "BB_A320/A320", Model: "A320", CSL: true},
+		}},
+	}
+
+	want := map[string]map[string][]string{
+		"LUFTHANSA": {"A320": {"BB_A320/A320_DLH"}},
+		"":          {"A320": {"BB_A320/A320"}},
+	}
+	if got := idx.ByAirline(db, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("%+v != %+v", got, want)
+	}
+}
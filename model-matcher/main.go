@@ -1,180 +1,502 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
-	"encoding/xml"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 var FSXRoot = "/mnt/c/Program Files (x86)/Steam/steamapps/common/FSX"
 
+const indexCacheFile = ".cache/model-index.json"
+
 func main() {
 	if d := os.Getenv("FSX_ROOT"); d != "" {
 		FSXRoot = d
 	}
 
 	watch := flag.Bool("watch", false, "Monitor the vatsim API and update the ruleset when never-seen-before aircraft appear.")
+	formatName := flag.String("format", "vpilot", "Rule set format: vpilot, swift for a swift model set, or xpilot for a CSL package in the first -csl directory.")
+	dryRun := flag.Bool("dry-run", false, "Print changes to the rule sets, but don't write them, icao.json, feed snapshots or the index cache. Pins and bans made in serve are saved.")
+	cslPath := flag.String("csl", "", "List of X-Plane CSL directories to scan, separated by "+string(os.PathListSeparator)+".")
+	snapshotDir := flag.String("snapshots", defaultSnapshotDir, "Record each fetched feed in this directory; empty to disable.")
+	snapshotAge := flag.Duration("snapshot-age", 0, "Remove feed snapshots older than this; 0 to keep all. With the feed polled every ten minutes, a week is about a thousand small files.")
+	replayDir := flag.String("replay", "", "Build the wanted aircraft from the feed snapshots in this directory, instead of icao.json and the live feed.")
+	verbose := flag.Bool("v", false, "Log debug messages.")
+	logJSON := flag.Bool("log-json", false, "Log in JSON format.")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s [flags] coverage [-n SNAPSHOTS] [-missing FILE.csv] [LOCATION]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s [flags] serve [-addr ADDRESS]\n\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	setupLogging(*verbose, *logJSON)
+
+	cmd := flag.Arg(0)
+	switch cmd {
+	case "", "coverage", "serve":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *watch && *replayDir != "" {
+		fatal("-watch and -replay cannot be combined")
+	}
+	live := *replayDir == ""
+
+	var cslDirs []string
+	if *cslPath != "" {
+		cslDirs = filepath.SplitList(*cslPath)
+	}
+
+	newFormat, ok := ruleFormats[*formatName]
+	if !ok {
+		fatal("Unknown rule set format", "format", *formatName)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// for each model in the database, look
 
 	db := &Database{} // maps Airline codes to sets of aircraft codes
-	if f, err := os.Open("icao.json"); err != nil {
-		log.Println(err)
-	} else {
-		json.NewDecoder(f).Decode(db)
-		f.Close()
+	if live {
+		if f, err := os.Open("icao.json"); err != nil {
+			slog.Warn("Cannot read wanted aircraft", "err", err)
+		} else {
+			json.NewDecoder(f).Decode(db)
+			f.Close()
+		}
 	}
 
 	if f, err := os.Open("EuroScope/EDBB/ICAO_Aircraft.txt"); err != nil {
-		log.Fatal(err)
+		fatal("Cannot read ICAO aircraft", "err", err)
 	} else {
 		db.ReadEuroScopeICAO(f)
 		f.Close()
 	}
 
 	if f, err := os.Open("EuroScope/EDBB/ICAO_Airlines.txt"); err != nil {
-		log.Fatal(err)
+		fatal("Cannot read ICAO airlines", "err", err)
 	} else {
 		db.ReadEuroScopeICAO(f)
 		f.Close()
 	}
 
 	if f, err := os.Open("model-matcher/ModelMatchingData.xml.gz"); err != nil {
-		log.Fatal(err)
+		fatal("Cannot read vPilot model data", "err", err)
 	} else {
 		r, err := gzip.NewReader(f)
 		if err != nil {
-			log.Fatal(err)
+			fatal("Cannot read vPilot model data", "err", err)
 		}
 		db.ReadVPilotModelData(r)
 		r.Close()
 		f.Close()
 	}
 
+	if f, err := os.Open("model-matcher/similar-types.txt"); err != nil {
+		fatal("Cannot read similar types", "err", err)
+	} else {
+		db.ReadSimilarTypes(f)
+		f.Close()
+	}
+
+	if f, err := os.Open("model-matcher/type-aliases.txt"); err != nil {
+		fatal("Cannot read type aliases", "err", err)
+	} else {
+		db.ReadTypeAliases(f)
+		f.Close()
+	}
+
+	if !live {
+		snapshots, err := LoadSnapshots(*replayDir, 0)
+		if err != nil {
+			fatal("Cannot read feed snapshots", "err", err)
+		}
+		db.Replay(snapshots)
+		slog.Info("Replayed feed snapshots", "dir", *replayDir, "snapshots", len(snapshots))
+	}
+
 	// TODO: Figure out which charset works for the txt files. It's something
 	// exotic. See for instance db.EuroScopeICAO["LAU"][0]. That's supposed to be
 	// "Líneas Aéreas Suramericanas - Colombia"
 	//fmt.Println(db.EuroScopeICAO["LAU"][0], []byte(db.EuroScopeICAO["LAU"][0][:8]))
 
-	index := make(map[string]map[string][]string) // Airline to Aircraft to Titles
-	/*
-		index, err := CreateIndex()
-		if err != nil {
-			log.Fatal(err)
-		}
-	*/
+	index, err := LoadIndex(indexCacheFile)
+	if err != nil {
+		slog.Warn("Cannot read index cache", "err", err)
+	}
+	format, err := newFormat(index, cslDirs)
+	if err != nil {
+		fatal("Cannot use rule set format", "format", *formatName, "err", err)
+	}
 
 	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		if err := updateDB(db); err != nil {
-			log.Println(err)
-		}
-		wg.Done()
-	}()
+	var snapshot Snapshot
+	var feedErr error
+	if cmd == "" && live {
+		wg.Add(1)
+		go func() {
+			snapshot, feedErr = updateDB(ctx, db)
+			wg.Done()
+		}()
+	}
 
-	paths, err := filepath.Glob(filepath.Join(FSXRoot, "SimObjects/Airplanes/*/aircraft.cfg"))
-	if err != nil {
-		log.Fatal(err)
+	if err := index.Scan(ctx, FSXRoot, cslDirs); err != nil {
+		if ctx.Err() != nil {
+			// Interrupted; the rule sets would be based on half an index.
+			return
+		}
+		fatal("Cannot scan installed models", "err", err)
+	}
+	saveIndex := func() {
+		if *dryRun {
+			return
+		}
+		if err := index.Save(indexCacheFile); err != nil {
+			slog.Warn("Cannot write index cache", "err", err)
+		}
+	}
+	saveIndex()
+	for _, s := range index.Broken() {
+		slog.Warn("Broken livery", "model", s)
+	}
+	for _, s := range index.UnresolvedTypes(db) {
+		slog.Warn("Unresolved aircraft type", "atc_model", s)
 	}
 
-	for _, path := range paths {
-		models, err := AircraftConfig(path)
-		if err != nil {
-			log.Println(path, err)
-			continue
+	if cmd == "coverage" {
+		dir := *snapshotDir
+		if !live {
+			dir = *replayDir
 		}
-		for _, m := range models {
-			if index[m.AirlineName] == nil {
-				index[m.AirlineName] = make(map[string][]string)
-			}
-			index[m.AirlineName][m.Model] = append(index[m.AirlineName][m.Model], m.Title)
+		libraries := []library{{"FSX", index.ByAirline(db, false)}}
+		if len(cslDirs) > 0 {
+			libraries = append(libraries, library{"CSL", index.ByAirline(db, true)})
 		}
+		coverage(libraries, db, dir, flag.Args()[1:])
+		return
+	}
+
+	if cmd == "serve" {
+		serve(ctx, index.ByAirline(db, format.CSL()), db, format, *dryRun, flag.Args()[1:])
+		return
 	}
 
 	wg.Wait()
-	if err := saveMappings(index, db); err != nil {
-		log.Println(err)
+	if ctx.Err() != nil {
+		return
+	}
+	switch {
+	case !live:
+	case feedErr != nil:
+		slog.Error("Cannot update wanted aircraft", "err", feedErr)
+	default:
+		saveFeed(db, snapshot, *snapshotDir, *snapshotAge, *dryRun)
+	}
+	if err := saveMappings(index.ByAirline(db, format.CSL()), db, loadOverrides(), format, *dryRun); err != nil {
+		slog.Error("Cannot save rule sets", "err", err)
 	}
 
 	if !*watch {
 		return
 	}
 
-	for range time.Tick(10 * time.Minute) {
-		if err := updateDB(db); err != nil {
-			log.Println(err)
-			continue
+	changed := make(chan []string)
+	go WatchModels(ctx, FSXRoot, cslDirs, time.Minute, changed)
+
+	// Poll the feed every feedInterval, or sooner with exponential backoff
+	// after errors.
+	backoff := feedRetryMin
+	next := time.NewTimer(feedInterval)
+	defer next.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Shutting down")
+			if err := saveDB(db, *dryRun); err != nil {
+				slog.Error("Cannot save wanted aircraft", "err", err)
+			}
+			saveIndex()
+			return
+		case <-next.C:
+			snapshot, err := updateDB(ctx, db)
+			if err != nil {
+				slog.Warn("Cannot update wanted aircraft", "err", err, "retry", backoff)
+				next.Reset(backoff)
+				if backoff *= 2; backoff > feedInterval {
+					backoff = feedInterval
+				}
+				continue
+			}
+			backoff = feedRetryMin
+			next.Reset(feedInterval)
+			saveFeed(db, snapshot, *snapshotDir, *snapshotAge, *dryRun)
+		case paths := <-changed:
+			slog.Info("Installed models changed", "files", len(paths))
+			if err := index.Update(ctx, paths...); err != nil {
+				continue // canceled; shut down
+			}
+			saveIndex()
 		}
-		if err := saveMappings(index, db); err != nil {
-			log.Println(err)
+		// The overrides may have been edited with the serve command in the
+		// meantime.
+		if err := saveMappings(index.ByAirline(db, format.CSL()), db, loadOverrides(), format, *dryRun); err != nil {
+			slog.Error("Cannot save rule sets", "err", err)
 		}
 	}
 }
 
-func updateDB(db *Database) error {
-	res, err := http.Get("http://api.vateud.net/online/pilots/ed.json")
+func setupLogging(verbose, asJSON bool) {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if asJSON {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
+}
+
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+const (
+	feedURL      = "http://api.vateud.net/online/pilots/ed.json"
+	feedInterval = 10 * time.Minute
+	feedRetryMin = 15 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+func fetchFeed(ctx context.Context) ([]APIStation, error) {
+	req, err := http.NewRequest("GET", feedURL, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	res, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
 	}
+	defer res.Body.Close()
+
 	if res.StatusCode != 200 {
-		return errors.New(res.Status)
+		return nil, errors.New(res.Status)
 	}
 
 	var m []APIStation
-
-	json.NewDecoder(res.Body).Decode(&m)
+	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
+		return nil, err
+	}
 	io.Copy(ioutil.Discard, res.Body)
-	res.Body.Close()
+
+	return m, nil
+}
+
+// updateDB adds the aircraft that are currently online to db.
+func updateDB(ctx context.Context, db *Database) (Snapshot, error) {
+	s := Snapshot{Time: time.Now()}
+	m, err := fetchFeed(ctx)
+	if err != nil {
+		return s, err
+	}
+	s.Stations = m
 
 	for _, x := range m {
 		db.Add(x)
 	}
+	slog.Debug("Updated wanted aircraft", "pilots", len(m))
 
-	b, err := json.MarshalIndent(db, "", "  ")
+	return s, nil
+}
+
+// saveFeed writes the wanted aircraft to icao.json and records the snapshot
+// of the feed in snapshotDir, removing snapshots older than maxAge, unless
+// dryRun is set.
+func saveFeed(db *Database, s Snapshot, snapshotDir string, maxAge time.Duration, dryRun bool) {
+	if err := saveDB(db, dryRun); err != nil {
+		slog.Error("Cannot save wanted aircraft", "err", err)
+	}
+	if dryRun || snapshotDir == "" {
+		return
+	}
+	if err := SaveSnapshot(snapshotDir, s); err != nil {
+		slog.Error("Cannot save feed snapshot", "err", err)
+	}
+	if maxAge <= 0 {
+		return
+	}
+	if err := PruneSnapshots(snapshotDir, maxAge, s.Time); err != nil {
+		slog.Error("Cannot remove old feed snapshots", "err", err)
+	}
+}
+
+// coverage implements the coverage command. The coverage of FSX and CSL models
+// is reported separately, because no client can use both.
+func coverage(libraries []library, db *Database, snapshotDir string, args []string) {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	n := fs.Int("n", 6, "Number of recent feed snapshots to analyze; 0 for all.")
+	missing := fs.String("missing", "", "Write the airlines and types without FSX model to this CSV file, for woai-install gaps.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s coverage [-n SNAPSHOTS] [-missing FILE.csv] [LOCATION]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(os.Stderr, "LOCATION is an ICAO airport code such as EDDT, a prefix such as ED, or a")
+		fmt.Fprintln(os.Stderr, "comma separated list of them, for instance the airports of a FIR.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	snapshots, err := LoadSnapshots(snapshotDir, *n)
 	if err != nil {
-		return err
+		fatal("Cannot read feed snapshots", "err", err)
+	}
+	if len(snapshots) == 0 {
+		fatal("No feed snapshots; run without command first", "dir", snapshotDir)
+	}
+
+	for i, l := range libraries {
+		if len(libraries) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s models: ", l.name)
+		}
+		c := ComputeCoverage(l.index, db, snapshots, fs.Arg(0))
+		c.Print(os.Stdout)
+
+		if *missing == "" || l.name != "FSX" {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := c.WriteMissing(&buf); err != nil {
+			fatal("Cannot write missing models", "err", err)
+		}
+		if err := writeFileAtomic(*missing, buf.Bytes(), 0644); err != nil {
+			fatal("Cannot write missing models", "err", err)
+		}
 	}
-	return ioutil.WriteFile("icao.json", b, 0644)
 }
 
-type RuleSet struct {
-	XMLName xml.Name `xml:"ModelMatchRuleSet"`
-	Rules   []Rule
+// A library is a set of installed models, as returned by Index.ByAirline.
+type library struct {
+	name  string
+	index map[string]map[string][]string
 }
 
-type Rule struct {
-	XMLName    xml.Name `xml:"ModelMatchRule"`
-	AL         string   `xml:"CallsignPrefix,attr"`
-	AC         string   `xml:"TypeCode,attr"`
-	Model      string   `xml:"ModelName,attr"`
-	Substitute bool     `xml:"Substitute,attr,omitempty"`
+// loadOverrides reads the pinned and banned titles. Errors are logged and
+// result in empty overrides.
+func loadOverrides() *Overrides {
+	ov, err := LoadOverrides(overridesFile)
+	if err != nil {
+		slog.Warn("Cannot read overrides", "file", overridesFile, "err", err)
+	}
+	return ov
 }
 
-func saveMappings(index map[string]map[string][]string, db *Database) error {
-	rss := buildMapping2(index, db)
+// saveDB writes the wanted aircraft to icao.json, unless dryRun is set.
+func saveDB(db *Database, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic("icao.json", b, 0644)
+}
+
+// saveMappings updates the rule set files and prints the changes. Existing
+// rule sets without generated rules are updated too, so that rules for models
+// that are no longer installed or wanted are removed. If dryRun is set, the
+// changes are only printed.
+func saveMappings(index map[string]map[string][]string, db *Database, ov *Overrides, format RuleFormat, dryRun bool) error {
+	rss := buildMapping2(index, db, ov)
+
+	// All installed titles count as generated, banned ones included, so
+	// that existing rules using a banned title are replaced by rules
+	// without it.
+	generatedTitles := make(map[string]bool)
+	for _, acs := range index {
+		for _, titles := range acs {
+			for _, t := range titles {
+				generatedTitles[t] = true
+			}
+		}
+	}
+	for _, titles := range ov.Pins {
+		for _, t := range titles {
+			generatedTitles[t] = true
+		}
+	}
+
+	existing, err := format.Existing()
+	if err != nil {
+		return err
+	}
+	for _, airline := range existing {
+		if rss[airline] == nil {
+			rss[airline] = &RuleSet{}
+		}
+	}
+
+	airlines := make([]string, 0, len(rss))
+	for airline := range rss {
+		airlines = append(airlines, airline)
+	}
+	sort.Strings(airlines)
+
+	for _, airline := range airlines {
+		fname := format.File(airline)
 
-	for airline, rs := range rss {
-		b, err := xml.MarshalIndent(rs, "", "  ")
+		existing, err := readRuleSetFile(fname, format)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		fname := filepath.Join("vPilot Files/Model Matching Rule Sets", airline+".vrm")
-		if err := ioutil.WriteFile(fname, append([]byte(xml.Header), b...), 0644); err != nil {
+		rs := MergeRuleSets(existing, rss[airline], generatedTitles)
+		diff := DiffRuleSets(existing, rs)
+		if diff.Empty() {
+			continue
+		}
+		diff.Print(os.Stdout, airline)
+		if dryRun {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := format.Write(&buf, airline, rs); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(fname, buf.Bytes(), 0644); err != nil {
 			return err
 		}
 	}
@@ -182,61 +504,163 @@ func saveMappings(index map[string]map[string][]string, db *Database) error {
 	return nil
 }
 
-func buildMapping2(index map[string]map[string][]string, db *Database) map[string]*RuleSet {
+// readRuleSetFile reads an existing rule set file. It returns nil if the file
+// doesn't exist.
+func readRuleSetFile(fname string, format RuleFormat) (*RuleSet, error) {
+	f, err := os.Open(fname)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rs, err := format.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("Parse %s: %v", fname, err)
+	}
+	return rs, nil
+}
+
+func buildMapping2(index map[string]map[string][]string, db *Database, ov *Overrides) map[string]*RuleSet {
 	rss := make(map[string]*RuleSet)
+	filtered := ov.Apply(index)
 
-	for al, acs := range db.All() {
-		alName := db.AirlineNames[al]
-		if alName == "" {
-			log.Printf("No name for %s\n", al)
+	wanted, pinned := ov.Wanted(db)
+
+	for al, acs := range wanted {
+		rsName, alName := db.RuleSetName(al)
+		if rsName == "" {
+			slog.Debug("No name for airline", "airline", al)
 			continue
 		}
 
-		if len(index[alName]) == 0 {
-			log.Printf("No models for %s %s; want %+v\n", al, alName, acs)
+		if len(index[alName]) == 0 && !pinned[al] {
+			slog.Debug("No models for airline", "airline", al, "name", alName, "want", acs)
 			continue
 		}
 
-		rs := &RuleSet{}
-		rss[alName] = rs
+		rs := rss[rsName]
+		if rs == nil {
+			rs = &RuleSet{}
+			rss[rsName] = rs
+		}
 
 		for ac, urgent := range acs {
-			candidates := []string{ac}
-			for _, alts := range db.AircraftAlts {
-				if !alts[ac] {
-					continue
-				}
-				for alt := range alts {
-					candidates = append(candidates, alt)
+			r, ok := ruleFor(filtered, db, ov, al, alName, ac)
+			if !ok {
+				if urgent {
+					slog.Info("No match", "airline", al, "name", alName, "aircraft", ac)
 				}
+				continue
 			}
+			rs.Rules = append(rs.Rules, r)
+		}
+	}
 
-			for i, c := range candidates {
-				if titles := index[alName][c]; len(titles) > 0 {
-					rs.Rules = append(rs.Rules, Rule{
-						AL:         al,
-						AC:         ac,
-						Model:      strings.Join(titles, "//"),
-						Substitute: i > 0,
-					})
-					goto next_model
-				}
-			}
-			if urgent {
-				log.Printf("No match: %s %s %s\n", al, alName, ac)
-			}
-		next_model:
+	return rss
+}
+
+// ruleFor returns the rule for callsign prefix al and type code ac, whose
+// models are indexed under alName. Pinned titles take precedence over the
+// installed models. index must not contain banned titles; see
+// Overrides.Apply.
+func ruleFor(index map[string]map[string][]string, db *Database, ov *Overrides, al, alName, ac string) (Rule, bool) {
+	titles, substitute := ov.Pinned(al, ac), false
+	if len(titles) == 0 {
+		titles, substitute = findModels(index, db, alName, ac)
+	}
+	if len(titles) == 0 {
+		return Rule{}, false
+	}
+	return Rule{
+		AL:         al,
+		AC:         ac,
+		Model:      strings.Join(titles, "//"),
+		Substitute: substitute,
+	}, true
+}
+
+// writeFileAtomic is like ioutil.WriteFile, but readers of fname see either
+// the old or the new content, never a partially written file. The data is
+// written to a temporary file in the same directory, which is then renamed.
+func writeFileAtomic(fname string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), fname)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// findModels returns the titles of the installed models of airline alName
+// and aircraft type ac. If there are none, models of similar types are
+// returned and substitute is true.
+func findModels(index map[string]map[string][]string, db *Database, alName, ac string) (titles []string, substitute bool) {
+	if titles := index[alName][ac]; len(titles) > 0 {
+		return titles, false
+	}
+
+	for _, alt := range similarTypes(db, ac) {
+		if titles := index[alName][alt]; len(titles) > 0 {
+			return titles, true
 		}
+	}
 
+	// Without liveries, any model of the type will do.
+	if !db.Liveried(ac) {
+		airlines := make([]string, 0, len(index))
+		for al := range index {
+			airlines = append(airlines, al)
+		}
+		sort.Strings(airlines)
+		for _, al := range airlines {
+			if titles := index[al][ac]; len(titles) > 0 {
+				return titles, true
+			}
+		}
 	}
+	return nil, false
+}
 
-	return rss
+// similarTypes returns the sorted type codes that may substitute ac.
+func similarTypes(db *Database, ac string) []string {
+	var alts []string
+	for _, set := range db.AircraftAlts {
+		if !set[ac] {
+			continue
+		}
+		for alt := range set {
+			if alt != ac {
+				alts = append(alts, alt)
+			}
+		}
+	}
+	sort.Strings(alts)
+	return alts
 }
 
 func dump(y interface{}) {
 	b, err := json.MarshalIndent(y, "", "  ")
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 
 	fmt.Println(string(b))
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "Lufthansa.vrm")
+	for _, content := range []string{"old", "new"} {
+		if err := writeFileAtomic(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("content == %q, want %q", b, content)
+		}
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 {
+		t.Fatalf("%d files in %s, want 1", len(fis), dir)
+	}
+	if fis[0].Mode().Perm() != 0644 {
+		t.Errorf("mode == %v, want 0644", fis[0].Mode())
+	}
+}
+
+func TestSaveMappings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// Nothing is wanted for Condor anymore, but the rule set has a
+	// generated rule and a foreign one.
+	format := vrmFormat{}
+	fname := format.File("Condor")
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := format.Write(&buf, "Condor", &RuleSet{Rules: []Rule{
+		{AL: "CFG", AC: "B753", Model: "WoA Condor B753"},
+		{AL: "CFG", AC: "A320", Model: "Other Library A320"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fname, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index := map[string]map[string][]string{
+		"CONDOR": {"B753": {"WoA Condor B753"}},
+	}
+	if err := saveMappings(index, &Database{}, &Overrides{}, format, false); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := readRuleSetFile(fname, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rs.Rules {
+		rs.Rules[i].XMLName.Local = ""
+	}
+	want := []Rule{{AL: "CFG", AC: "A320", Model: "Other Library A320"}}
+	if !reflect.DeepEqual(rs.Rules, want) {
+		t.Errorf("rules == %+v, want %+v", rs.Rules, want)
+	}
+}
+
+func TestFindModels(t *testing.T) {
+	db := &Database{LiveriedTypeCodes: map[string]bool{"A320": true, "A321": true}}
+	db.ReadSimilarTypes(strings.NewReader("A320 A321\n"))
+
+	index := map[string]map[string][]string{
+		"CONDOR":    {"C172": {"Cessna Skyhawk Condor"}},
+		"LUFTHANSA": {"A320": {"WoA Lufthansa A320"}},
+	}
+
+	testCases := []struct {
+		alName, ac     string
+		wantTitles     []string
+		wantSubstitute bool
+	}{
+		{"LUFTHANSA", "A320", []string{"WoA Lufthansa A320"}, false},
+		{"LUFTHANSA", "A321", []string{"WoA Lufthansa A320"}, true},
+		// Unliveried types of other airlines will do.
+		{"LUFTHANSA", "C172", []string{"Cessna Skyhawk Condor"}, true},
+		{"CONDOR", "A320", nil, false},
+	}
+
+	for _, tc := range testCases {
+		titles, substitute := findModels(index, db, tc.alName, tc.ac)
+		if !reflect.DeepEqual(titles, tc.wantTitles) || substitute != tc.wantSubstitute {
+			t.Errorf("findModels(%s, %s) == %v, %v, want %v, %v", tc.alName, tc.ac, titles, substitute, tc.wantTitles, tc.wantSubstitute)
+		}
+	}
+}
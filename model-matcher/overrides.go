@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+)
+
+const overridesFile = "model-overrides.json"
+
+// Overrides are adjustments to the generated rules made by hand, usually with
+// the serve command.
+type Overrides struct {
+	// Pins maps a callsign prefix and type code, such as "DLH A320", to the
+	// titles that are used for it instead of the matching installed models.
+	Pins map[string][]string `json:",omitempty"`
+
+	// Bans is the set of titles that are never used, neither as exact
+	// match nor as substitute.
+	Bans map[string]bool `json:",omitempty"`
+}
+
+// LoadOverrides reads the overrides from fname. A missing file is not an
+// error; the overrides are empty then.
+func LoadOverrides(fname string) (*Overrides, error) {
+	o := &Overrides{}
+	f, err := os.Open(fname)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return o, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(o); err != nil {
+		return &Overrides{}, err
+	}
+	return o, nil
+}
+
+func (o *Overrides) Save(fname string) error {
+	b, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fname, b, 0644)
+}
+
+// Pinned returns the titles pinned for callsign prefix al and type code ac.
+func (o *Overrides) Pinned(al, ac string) []string {
+	return o.Pins[al+" "+ac]
+}
+
+// Pin pins titles for callsign prefix al and type code ac. An empty list of
+// titles removes the pin.
+func (o *Overrides) Pin(al, ac string, titles []string) {
+	if len(titles) == 0 {
+		delete(o.Pins, al+" "+ac)
+		return
+	}
+	if o.Pins == nil {
+		o.Pins = make(map[string][]string)
+	}
+	titles = append([]string(nil), titles...)
+	sort.Strings(titles)
+	o.Pins[al+" "+ac] = titles
+}
+
+// Ban bans or unbans a title.
+func (o *Overrides) Ban(title string, ban bool) {
+	if !ban {
+		delete(o.Bans, title)
+		return
+	}
+	if o.Bans == nil {
+		o.Bans = make(map[string]bool)
+	}
+	o.Bans[title] = true
+}
+
+// Wanted returns the wanted aircraft of db, as returned by Database.All,
+// plus the pinned ones. pinned is the set of callsign prefixes with pins.
+func (o *Overrides) Wanted(db *Database) (wanted map[string]map[string]bool, pinned map[string]bool) {
+	wanted = db.All()
+	pinned = make(map[string]bool)
+	for key := range o.Pins {
+		f := strings.Fields(key)
+		if len(f) != 2 {
+			continue
+		}
+		al, ac := f[0], f[1]
+		pinned[al] = true
+		if wanted[al] == nil {
+			wanted[al] = make(map[string]bool)
+		}
+		if _, ok := wanted[al][ac]; !ok {
+			wanted[al][ac] = false
+		}
+	}
+	return wanted, pinned
+}
+
+// Apply returns a copy of index, as returned by Index.ByAirline, without the
+// banned titles.
+func (o *Overrides) Apply(index map[string]map[string][]string) map[string]map[string][]string {
+	if len(o.Bans) == 0 {
+		return index
+	}
+
+	x := make(map[string]map[string][]string, len(index))
+	for alName, acs := range index {
+		x[alName] = make(map[string][]string, len(acs))
+		for ac, titles := range acs {
+			var keep []string
+			for _, t := range titles {
+				if !o.Bans[t] {
+					keep = append(keep, t)
+				}
+			}
+			if len(keep) > 0 {
+				x[alName][ac] = keep
+			}
+		}
+	}
+	return x
+}
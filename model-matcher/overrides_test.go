@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "model-overrides.json")
+
+	ov, err := LoadOverrides(fname)
+	if err != nil {
+		t.Fatalf("LoadOverrides of missing file: %v", err)
+	}
+	ov.Pin("DLH", "A321", []string{"WoA Lufthansa A320"})
+	ov.Ban("WoA Lufthansa B744 old", true)
+	ov.Ban("WoA Lufthansa A319", true)
+	ov.Ban("WoA Lufthansa A319", false)
+	if err := ov.Save(fname); err != nil {
+		t.Fatal(err)
+	}
+	ov, err = LoadOverrides(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("DLH\tLufthansa - Germany\tLUFTHANSA\n"))
+	db.ReadSimilarTypes(strings.NewReader("A319 A320 A321\n"))
+	db.add("DLH", "B744", false)
+	db.add("DLH", "A319", false)
+
+	index := map[string]map[string][]string{
+		"LUFTHANSA": {
+			"A320": {"WoA Lufthansa A320"},
+			"A321": {"WoA Lufthansa A321"},
+			"B744": {"WoA Lufthansa B744", "WoA Lufthansa B744 old"},
+		},
+	}
+
+	rss := buildMapping2(index, db, ov)
+	rs := rss["LUFTHANSA"]
+	if rs == nil {
+		t.Fatalf("no rule set for LUFTHANSA: %v", rss)
+	}
+	rs.Sort()
+
+	want := []Rule{
+		{AL: "DLH", AC: "A319", Model: "WoA Lufthansa A320", Substitute: true},
+		{AL: "DLH", AC: "A321", Model: "WoA Lufthansa A320"},
+		{AL: "DLH", AC: "B744", Model: "WoA Lufthansa B744"},
+	}
+	if !reflect.DeepEqual(rs.Rules, want) {
+		t.Errorf("rules ==\n%v\nwant\n%v", rs.Rules, want)
+	}
+
+	ov.Pin("DLH", "A321", nil)
+	if len(ov.Pins) != 0 {
+		t.Errorf("Pins == %v after unpinning, want none", ov.Pins)
+	}
+}
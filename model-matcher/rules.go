@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type RuleSet struct {
+	XMLName xml.Name `xml:"ModelMatchRuleSet"`
+	Rules   []Rule   `xml:"ModelMatchRule"`
+}
+
+type Rule struct {
+	XMLName    xml.Name `xml:"ModelMatchRule"`
+	AL         string   `xml:"CallsignPrefix,attr"`
+	AC         string   `xml:"TypeCode,attr"`
+	Model      string   `xml:"ModelName,attr"`
+	Substitute bool     `xml:"Substitute,attr,omitempty"`
+
+	// Locked marks hand-written rules. They are never replaced or removed
+	// by generated rules. vPilot ignores the attribute.
+	Locked bool `xml:"Locked,attr,omitempty"`
+}
+
+func (r Rule) key() string {
+	return r.AL + " " + r.AC
+}
+
+// Titles returns the model titles of the rule. vPilot separates multiple
+// titles with "//" and picks one of them at random.
+func (r Rule) Titles() []string {
+	return strings.Split(r.Model, "//")
+}
+
+// ReadRuleSet decodes a vPilot model matching rule set (.vrm file).
+func ReadRuleSet(r io.Reader) (*RuleSet, error) {
+	rs := &RuleSet{}
+	if err := xml.NewDecoder(r).Decode(rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Sort orders the rules by callsign prefix and type code.
+func (rs *RuleSet) Sort() {
+	sort.Slice(rs.Rules, func(i, j int) bool {
+		a, b := rs.Rules[i], rs.Rules[j]
+		if a.AL != b.AL {
+			return a.AL < b.AL
+		}
+		return a.AC < b.AC
+	})
+}
+
+// MergeRuleSets merges the generated rules into an existing rule set.
+// generatedTitles is the set of all titles we generate rules for; existing
+// rules that don't reference any of them have been written by someone else
+// and are preserved unless a generated rule replaces them. Locked rules
+// always win.
+func MergeRuleSets(existing, generated *RuleSet, generatedTitles map[string]bool) *RuleSet {
+	merged := &RuleSet{}
+	if existing == nil {
+		merged.Rules = append(merged.Rules, generated.Rules...)
+		merged.Sort()
+		return merged
+	}
+
+	locked := make(map[string]bool)
+	replaced := make(map[string]bool, len(generated.Rules))
+	for _, r := range existing.Rules {
+		if r.Locked {
+			locked[r.key()] = true
+		}
+	}
+
+	for _, r := range generated.Rules {
+		if locked[r.key()] {
+			continue
+		}
+		replaced[r.key()] = true
+		merged.Rules = append(merged.Rules, r)
+	}
+
+	for _, r := range existing.Rules {
+		switch {
+		case r.Locked:
+		case replaced[r.key()]:
+			continue
+		case isGenerated(r, generatedTitles):
+			// Generated in an earlier run but no longer wanted or installed.
+			continue
+		}
+		merged.Rules = append(merged.Rules, r)
+	}
+
+	merged.Sort()
+	return merged
+}
+
+func isGenerated(r Rule, generatedTitles map[string]bool) bool {
+	for _, t := range r.Titles() {
+		if generatedTitles[t] {
+			return true
+		}
+	}
+	return false
+}
+
+type RuleDiff struct {
+	Added   []Rule
+	Removed []Rule
+	Changed [][2]Rule // old and new rule
+}
+
+func (d RuleDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffRuleSets compares two rule sets by callsign prefix and type code. Either
+// may be nil.
+func DiffRuleSets(old, new *RuleSet) RuleDiff {
+	var d RuleDiff
+
+	before := make(map[string]Rule)
+	if old != nil {
+		for _, r := range old.Rules {
+			before[r.key()] = r
+		}
+	}
+
+	after := make(map[string]bool)
+	if new != nil {
+		for _, r := range new.Rules {
+			after[r.key()] = true
+			o, ok := before[r.key()]
+			switch {
+			case !ok:
+				d.Added = append(d.Added, r)
+			case o.Model != r.Model || o.Substitute != r.Substitute || o.Locked != r.Locked:
+				d.Changed = append(d.Changed, [2]Rule{o, r})
+			}
+		}
+	}
+
+	if old != nil {
+		for _, r := range old.Rules {
+			if !after[r.key()] {
+				d.Removed = append(d.Removed, r)
+			}
+		}
+	}
+
+	return d
+}
+
+func (d RuleDiff) Print(w io.Writer, airline string) {
+	if d.Empty() {
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", airline)
+	for _, r := range d.Added {
+		fmt.Fprintf(w, "  + %s %s %q\n", r.AL, r.AC, r.Model)
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(w, "  - %s %s %q\n", r.AL, r.AC, r.Model)
+	}
+	for _, x := range d.Changed {
+		fmt.Fprintf(w, "  ~ %s %s %q -> %q\n", x[1].AL, x[1].AC, x[0].Model, x[1].Model)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRuleSet(t *testing.T) {
+	given := `<?xml version="1.0" encoding="UTF-8"?>
+<ModelMatchRuleSet>
+  <ModelMatchRule CallsignPrefix="DLH" TypeCode="A320" ModelName="A//B" />
+  <ModelMatchRule CallsignPrefix="DLH" TypeCode="A321" ModelName="C" Substitute="true" Locked="true" />
+</ModelMatchRuleSet>`
+
+	rs, err := ReadRuleSet(strings.NewReader(given))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Rule{
+		{AL: "DLH", AC: "A320", Model: "A//B"},
+		{AL: "DLH", AC: "A321", Model: "C", Substitute: true, Locked: true},
+	}
+	for i := range rs.Rules {
+		rs.Rules[i].XMLName.Local = ""
+	}
+	if !reflect.DeepEqual(rs.Rules, want) {
+		t.Errorf("%+v != %+v", rs.Rules, want)
+	}
+}
+
+func TestMergeRuleSets(t *testing.T) {
+	existing := &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "A320", Model: "Hand Written A320", Locked: true},
+		{AL: "DLH", AC: "A321", Model: "Other Library A321"},
+		{AL: "DLH", AC: "B744", Model: "Old B744"},
+		{AL: "DLH", AC: "CRJ9", Model: "Removed CRJ9"},
+	}}
+	generated := &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "B744", Model: "New B744"},
+		{AL: "DLH", AC: "A320", Model: "Generated A320"},
+		{AL: "DLH", AC: "A319", Model: "Generated A319"},
+	}}
+	titles := map[string]bool{
+		"Old B744":       true,
+		"New B744":       true,
+		"Removed CRJ9":   true,
+		"Generated A320": true,
+		"Generated A319": true,
+	}
+
+	got := MergeRuleSets(existing, generated, titles)
+	want := []Rule{
+		{AL: "DLH", AC: "A319", Model: "Generated A319"},
+		{AL: "DLH", AC: "A320", Model: "Hand Written A320", Locked: true},
+		{AL: "DLH", AC: "A321", Model: "Other Library A321"},
+		{AL: "DLH", AC: "B744", Model: "New B744"},
+	}
+	if !reflect.DeepEqual(got.Rules, want) {
+		t.Errorf("%+v != %+v", got.Rules, want)
+	}
+}
+
+func TestDiffRuleSets(t *testing.T) {
+	old := &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "A320", Model: "A"},
+		{AL: "DLH", AC: "A321", Model: "B"},
+	}}
+	new := &RuleSet{Rules: []Rule{
+		{AL: "DLH", AC: "A320", Model: "A"},
+		{AL: "DLH", AC: "A321", Model: "C"},
+		{AL: "DLH", AC: "B744", Model: "D"},
+	}}
+
+	d := DiffRuleSets(old, new)
+	if want := []Rule{new.Rules[2]}; !reflect.DeepEqual(d.Added, want) {
+		t.Errorf("Added: %+v != %+v", d.Added, want)
+	}
+	if len(d.Removed) != 0 {
+		t.Errorf("Removed: %+v, want none", d.Removed)
+	}
+	if want := [][2]Rule{{old.Rules[1], new.Rules[1]}}; !reflect.DeepEqual(d.Changed, want) {
+		t.Errorf("Changed: %+v != %+v", d.Changed, want)
+	}
+
+	d = DiffRuleSets(new, nil)
+	if len(d.Removed) != 3 {
+		t.Errorf("Removed: %+v, want all", d.Removed)
+	}
+}
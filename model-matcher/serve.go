@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxRows limits the number of rules shown on the search page.
+const maxRows = 200
+
+// serve implements the serve command.
+func serve(ctx context.Context, index map[string]map[string][]string, db *Database, format RuleFormat, dryRun bool, args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [-addr ADDRESS]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(os.Stderr, "Serves a web page to browse the wanted aircraft and the installed models,")
+		fmt.Fprintln(os.Stderr, "pin or ban titles, and write the rule sets.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	s := &server{
+		index:         index,
+		db:            db,
+		ov:            loadOverrides(),
+		overridesFile: overridesFile,
+		format:        format,
+		dryRun:        dryRun,
+		token:         newToken(),
+		addr:          *addr,
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	slog.Info("Serving", "url", "http://"+*addr+"/")
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		fatal("Cannot serve", "err", err)
+	}
+}
+
+// server is the web UI of the serve command. The index and the wanted
+// aircraft don't change while it runs; pins and bans are saved to
+// overridesFile as soon as they are made.
+//
+// Forms include token, and requests that change anything are rejected
+// without it, so that other web pages open in the browser cannot submit
+// them. Requests for other host names than the loopback ones and addr are
+// rejected, so that other web pages cannot get the token either, by
+// resolving their own name to a loopback address.
+type server struct {
+	mu            sync.Mutex
+	index         map[string]map[string][]string // as returned by Index.ByAirline
+	db            *Database
+	ov            *Overrides
+	overridesFile string
+	format        RuleFormat
+	dryRun        bool
+	token         string
+	addr          string // as given to -addr
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		fatal("Cannot create token", "err", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// checkPost tells whether r is a POST request from one of our forms, and
+// responds with an error otherwise.
+func (s *server) checkPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if o := r.Header.Get("Origin"); o != "" {
+		if u, err := url.Parse(o); err != nil || u.Host != r.Host {
+			http.Error(w, "Cross-origin request", http.StatusForbidden)
+			return false
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue("token")), []byte(s.token)) != 1 {
+		http.Error(w, "Invalid token; reload the page", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// allowedHost tells whether host, the Host header of a request, names this
+// server.
+func (s *server) allowedHost(host string) bool {
+	if host == s.addr {
+		return true
+	}
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	if name == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(strings.Trim(name, "[]")); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(s.addr); err == nil && h != "" && h == name {
+		return true
+	}
+	return false
+}
+
+func (s *server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.search)
+	mux.HandleFunc("/pin", s.pin)
+	mux.HandleFunc("/ban", s.ban)
+	mux.HandleFunc("/save", s.save)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHost(r.Host) {
+			http.Error(w, "Unknown host", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+type row struct {
+	AL, AC     string
+	RuleSet    string
+	Rule       Rule
+	Matched    bool
+	Pinned     bool
+	Candidates []candidate
+}
+
+type candidate struct {
+	Title  string
+	Type   string
+	Used   bool
+	Pinned bool
+	Banned bool
+}
+
+type searchPage struct {
+	Token         string
+	Airline, Type string
+	Rows          []row
+	Truncated     bool
+}
+
+// rows returns the wanted and pinned rules whose callsign prefix or airline
+// name matches airline and whose type code starts with ac.
+func (s *server) rows(airline, ac string) (rows []row, truncated bool) {
+	airline = strings.ToUpper(strings.TrimSpace(airline))
+	ac = strings.ToUpper(strings.TrimSpace(ac))
+
+	wanted, _ := s.ov.Wanted(s.db)
+	filtered := s.ov.Apply(s.index)
+	for al, acs := range wanted {
+		rsName, alName := s.db.RuleSetName(al)
+		if rsName == "" {
+			continue
+		}
+		if airline != "" && !strings.HasPrefix(al, airline) && !strings.Contains(strings.ToUpper(rsName), airline) {
+			continue
+		}
+
+		for t := range acs {
+			if !strings.HasPrefix(t, ac) {
+				continue
+			}
+			r, ok := ruleFor(filtered, s.db, s.ov, al, alName, t)
+			rows = append(rows, row{
+				AL:         al,
+				AC:         t,
+				RuleSet:    rsName,
+				Rule:       r,
+				Matched:    ok,
+				Pinned:     len(s.ov.Pinned(al, t)) > 0,
+				Candidates: s.candidates(al, alName, t, r),
+			})
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].AL != rows[j].AL {
+			return rows[i].AL < rows[j].AL
+		}
+		return rows[i].AC < rows[j].AC
+	})
+	if len(rows) > maxRows {
+		return rows[:maxRows], true
+	}
+	return rows, false
+}
+
+// candidates returns the titles that may be used for callsign prefix al and
+// type code ac: the installed models of the type and of similar types, and
+// the pinned titles. Banned titles are listed too, so that they can be
+// unbanned, but rules never use them.
+func (s *server) candidates(al, alName, ac string, r Rule) []candidate {
+	used := make(map[string]bool)
+	if r.Model != "" {
+		for _, t := range r.Titles() {
+			used[t] = true
+		}
+	}
+	pinned := make(map[string]bool)
+	for _, t := range s.ov.Pinned(al, ac) {
+		pinned[t] = true
+	}
+
+	var cs []candidate
+	seen := make(map[string]bool)
+	addTitle := func(title, t string) {
+		if seen[title] {
+			return
+		}
+		seen[title] = true
+		cs = append(cs, candidate{
+			Title:  title,
+			Type:   t,
+			Used:   used[title],
+			Pinned: pinned[title],
+			Banned: s.ov.Bans[title],
+		})
+	}
+
+	for _, t := range append([]string{ac}, similarTypes(s.db, ac)...) {
+		for _, title := range s.index[alName][t] {
+			addTitle(title, t)
+		}
+	}
+	for _, title := range s.ov.Pinned(al, ac) {
+		addTitle(title, "")
+	}
+	return cs
+}
+
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := searchPage{
+		Token:   s.token,
+		Airline: r.FormValue("airline"),
+		Type:    r.FormValue("type"),
+	}
+	if p.Airline != "" || p.Type != "" {
+		p.Rows, p.Truncated = s.rows(p.Airline, p.Type)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := searchTemplate.Execute(w, p); err != nil {
+		slog.Warn("Cannot render page", "err", err)
+	}
+}
+
+// pin replaces the pinned titles of a rule with the checked titles.
+func (s *server) pin(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPost(w, r) {
+		return
+	}
+
+	al, ac := r.PostForm.Get("al"), r.PostForm.Get("ac")
+	if al == "" || ac == "" {
+		http.Error(w, "Missing callsign prefix or type code", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ov.Pin(al, ac, r.PostForm["title"])
+	s.saveOverrides(w, r)
+}
+
+// ban bans the titles in the ban fields and unbans those in the unban
+// fields.
+func (s *server) ban(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPost(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range r.PostForm["ban"] {
+		s.ov.Ban(t, true)
+	}
+	for _, t := range r.PostForm["unban"] {
+		s.ov.Ban(t, false)
+	}
+	s.saveOverrides(w, r)
+}
+
+func (s *server) saveOverrides(w http.ResponseWriter, r *http.Request) {
+	if err := s.ov.Save(s.overridesFile); err != nil {
+		slog.Error("Cannot save overrides", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redirectBack(w, r)
+}
+
+// save writes the rule sets.
+func (s *server) save(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPost(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := saveMappings(s.index, s.db, s.ov, s.format, s.dryRun); err != nil {
+		slog.Error("Cannot save rule sets", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redirectBack(w, r)
+}
+
+// redirectBack redirects to the search page with the query of the submitted
+// form.
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	q := url.Values{}
+	for _, k := range []string{"airline", "type"} {
+		if v := r.FormValue(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+	http.Redirect(w, r, "/?"+q.Encode(), http.StatusSeeOther)
+}
+
+var searchTemplate = template.Must(template.New("search").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Model matching rules</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+.used { font-weight: bold; }
+.banned { text-decoration: line-through; color: #999; }
+.nomatch { color: #c00; }
+</style>
+</head>
+<body>
+<form method="get" action="/">
+Airline <input name="airline" value="{{.Airline}}" placeholder="DLH or Lufthansa">
+Type <input name="type" value="{{.Type}}" placeholder="A32">
+<button>Search</button>
+</form>
+<form method="post" action="/save">
+<input type="hidden" name="token" value="{{.Token}}">
+<input type="hidden" name="airline" value="{{.Airline}}">
+<input type="hidden" name="type" value="{{.Type}}">
+<p><button>Write rule sets</button></p>
+</form>
+{{if .Truncated}}<p>Only the first rules are shown; refine the search.</p>{{end}}
+{{range .Rows}}
+<form method="post" action="/pin">
+<input type="hidden" name="token" value="{{$.Token}}">
+<input type="hidden" name="airline" value="{{$.Airline}}">
+<input type="hidden" name="type" value="{{$.Type}}">
+<input type="hidden" name="al" value="{{.AL}}">
+<input type="hidden" name="ac" value="{{.AC}}">
+<table>
+<tr><th colspan="4">{{.AL}} {{.AC}} ({{.RuleSet}}):
+{{if .Pinned}}pinned{{else if not .Matched}}<span class="nomatch">no match</span>{{else if .Rule.Substitute}}substitute{{else}}exact{{end}}</th></tr>
+{{range .Candidates}}
+<tr class="{{if .Used}}used{{end}}{{if .Banned}} banned{{end}}">
+<td><input type="checkbox" name="title" value="{{.Title}}"{{if .Pinned}} checked{{end}}></td>
+<td>{{.Title}}</td>
+<td>{{.Type}}</td>
+<td>{{if .Banned}}<button name="unban" value="{{.Title}}" formaction="/ban">Unban</button>{{else}}<button name="ban" value="{{.Title}}" formaction="/ban">Ban</button>{{end}}</td>
+</tr>
+{{else}}
+<tr><td colspan="4">No installed models.</td></tr>
+{{end}}
+<tr><td colspan="4"><button>Pin checked titles</button></td></tr>
+</table>
+</form>
+{{else}}
+{{if or .Airline .Type}}<p>No wanted aircraft found.</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAllowedHost(t *testing.T) {
+	testCases := []struct {
+		addr, host string
+		want       bool
+	}{
+		{"localhost:8080", "localhost:8080", true},
+		{"localhost:8080", "127.0.0.1:8080", true},
+		{"localhost:8080", "[::1]:8080", true},
+		{"localhost:8080", "evil.example.com:8080", false},
+		{"localhost:8080", "192.168.1.5:8080", false},
+		{"192.168.1.5:8080", "192.168.1.5:8080", true},
+		{":8080", "fsx-pc:8080", false},
+	}
+
+	for _, tc := range testCases {
+		s := &server{addr: tc.addr}
+		if got := s.allowedHost(tc.host); got != tc.want {
+			t.Errorf("allowedHost(%q) with -addr %s == %v, want %v", tc.host, tc.addr, got, tc.want)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("DLH\tLufthansa - Germany\tLUFTHANSA\n"))
+	db.add("DLH", "A320", false)
+
+	s := &server{
+		index: map[string]map[string][]string{
+			"LUFTHANSA": {"A320": {"WoA Lufthansa A320", "AI Lufthansa A320"}},
+		},
+		db:            db,
+		ov:            &Overrides{},
+		overridesFile: filepath.Join(dir, "model-overrides.json"),
+		format:        vrmFormat{},
+		dryRun:        true,
+		token:         "secret",
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	get := func(query string) string {
+		res, err := http.Get(ts.URL + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	page := get("airline=lufthansa")
+	for _, x := range []string{"DLH A320", "WoA Lufthansa A320", "AI Lufthansa A320", "exact"} {
+		if !strings.Contains(page, x) {
+			t.Errorf("search page doesn't contain %q", x)
+		}
+	}
+	if page := get("airline=BER"); strings.Contains(page, "DLH A320") {
+		t.Errorf("search for BER shows DLH A320")
+	}
+
+	if !strings.Contains(page, `name="token" value="secret"`) {
+		t.Errorf("search page doesn't contain the token")
+	}
+
+	// From a page whose name resolves to the loopback address.
+	req, err := http.NewRequest("GET", ts.URL+"/?airline=DLH", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "evil.example.com"
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("GET / for evil.example.com: %s", res.Status)
+	}
+
+	// Without token, or from another site.
+	res, err = http.PostForm(ts.URL+"/ban", url.Values{"ban": {"AI Lufthansa A320"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /ban without token: %s", res.Status)
+	}
+	req, err = http.NewRequest("POST", ts.URL+"/ban", strings.NewReader("token=secret&ban=AI+Lufthansa+A320"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://evil.example.com")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("cross-origin POST /ban: %s", res.Status)
+	}
+
+	res, err = http.PostForm(ts.URL+"/pin", url.Values{
+		"token":   {"secret"},
+		"airline": {"DLH"},
+		"al":      {"DLH"},
+		"ac":      {"A320"},
+		"title":   {"AI Lufthansa A320"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 || res.Request.URL.RawQuery != "airline=DLH" {
+		t.Errorf("POST /pin: %s, redirected to %q", res.Status, res.Request.URL)
+	}
+
+	res, err = http.PostForm(ts.URL+"/ban", url.Values{"token": {"secret"}, "ban": {"WoA Lufthansa A320"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	ov, err := LoadOverrides(s.overridesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Overrides{
+		Pins: map[string][]string{"DLH A320": {"AI Lufthansa A320"}},
+		Bans: map[string]bool{"WoA Lufthansa A320": true},
+	}
+	if !reflect.DeepEqual(ov, want) {
+		t.Errorf("saved overrides == %+v, want %+v", ov, want)
+	}
+
+	if page := get("airline=DLH&type=A3"); !strings.Contains(page, "pinned") {
+		t.Errorf("search page doesn't show the pin")
+	}
+}
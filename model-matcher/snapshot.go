@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultSnapshotDir is where each fetched feed is recorded by default.
+const defaultSnapshotDir = "feed-snapshots"
+
+// snapshotTimeFormat is used for snapshot file names, so that sorting them
+// by name sorts them by time.
+const snapshotTimeFormat = "20060102T150405Z"
+
+// A Snapshot is the list of pilots that were online at a particular time.
+type Snapshot struct {
+	Time     time.Time
+	Stations []APIStation
+}
+
+// SaveSnapshot writes s into dir as gzipped JSON.
+func SaveSnapshot(dir string, s Snapshot) error {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(s.Stations); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fname := filepath.Join(dir, s.Time.UTC().Format(snapshotTimeFormat)+".json.gz")
+	return writeFileAtomic(fname, buf.Bytes(), 0644)
+}
+
+// LoadSnapshots reads the n most recent snapshots in dir, oldest first. If n
+// is zero or negative, all snapshots are read.
+func LoadSnapshots(dir string, n int) ([]Snapshot, error) {
+	snapshots, fnames, err := listSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && len(snapshots) > n {
+		snapshots = snapshots[len(snapshots)-n:]
+		fnames = fnames[len(fnames)-n:]
+	}
+
+	for i, fname := range fnames {
+		var err error
+		if snapshots[i].Stations, err = readSnapshotFile(fname); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshots, nil
+}
+
+// listSnapshots returns the snapshots in dir, oldest first, without their
+// stations, and their file names.
+func listSnapshots(dir string) ([]Snapshot, []string, error) {
+	x, err := filepath.Glob(filepath.Join(dir, "*.json.gz"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var snapshots []Snapshot
+	var fnames []string
+	for _, fname := range x {
+		t, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(filepath.Base(fname), ".json.gz"))
+		if err != nil {
+			continue // not a snapshot
+		}
+		snapshots = append(snapshots, Snapshot{Time: t})
+		fnames = append(fnames, fname)
+	}
+
+	sort.Sort(byTime{snapshots, fnames})
+	return snapshots, fnames, nil
+}
+
+// PruneSnapshots removes the snapshots in dir that are older than maxAge.
+func PruneSnapshots(dir string, maxAge time.Duration, now time.Time) error {
+	snapshots, fnames, err := listSnapshots(dir)
+	if err != nil {
+		return err
+	}
+	for i, s := range snapshots {
+		if now.Sub(s.Time) <= maxAge {
+			break
+		}
+		if err := os.Remove(fnames[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type byTime struct {
+	snapshots []Snapshot
+	fnames    []string
+}
+
+func (x byTime) Len() int           { return len(x.snapshots) }
+func (x byTime) Less(i, j int) bool { return x.snapshots[i].Time.Before(x.snapshots[j].Time) }
+func (x byTime) Swap(i, j int) {
+	x.snapshots[i], x.snapshots[j] = x.snapshots[j], x.snapshots[i]
+	x.fnames[i], x.fnames[j] = x.fnames[j], x.fnames[i]
+}
+
+func readSnapshotFile(fname string) ([]APIStation, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var x []APIStation
+	if err := json.NewDecoder(r).Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Replay adds the aircraft in the snapshots to the database, as if the
+// snapshots had been fetched from the live feed.
+func (d *Database) Replay(snapshots []Snapshot) {
+	for _, s := range snapshots {
+		for _, x := range s.Stations {
+			d.Add(x)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t0 := time.Date(2017, 9, 7, 18, 0, 0, 0, time.UTC)
+	given := []Snapshot{
+		{t0, []APIStation{{Callsign: "DLH1", Aircraft: "A320/L", Origin: "EDDT"}}},
+		{t0.Add(10 * time.Minute), []APIStation{{Callsign: "BER2", Aircraft: "B738"}}},
+		{t0.Add(20 * time.Minute), []APIStation{{Callsign: "DLH1", Aircraft: "A320/L", Origin: "EDDT"}, {Callsign: "DLH2", Aircraft: "H/B744/L"}}},
+	}
+
+	for _, s := range given {
+		if err := SaveSnapshot(dir, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "README.json.gz"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadSnapshots(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, given) {
+		t.Errorf("LoadSnapshots(0) == %+v, want %+v", got, given)
+	}
+
+	got, err = LoadSnapshots(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, given[1:]) {
+		t.Errorf("LoadSnapshots(2) == %+v, want %+v", got, given[1:])
+	}
+
+	if err := PruneSnapshots(dir, 15*time.Minute, t0.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadSnapshots(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, given[2:]) {
+		t.Errorf("LoadSnapshots after PruneSnapshots == %+v, want %+v", got, given[2:])
+	}
+
+	db := &Database{}
+	db.ReadEuroScopeICAO(strings.NewReader("DLH\tLufthansa - Germany\tLUFTHANSA\nBER\tAir Berlin - Germany\tAIR BERLIN\n"))
+	db.Replay(given)
+	want := map[string]map[string]bool{
+		"DLH": {"A320/L": true, "H/B744/L": false},
+		"BER": {"B738": false},
+	}
+	if !reflect.DeepEqual(db.Wanted, want) {
+		t.Errorf("Wanted == %+v, want %+v", db.Wanted, want)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// typeKey reduces a free-form aircraft type name to upper-case letters and
+// digits, so that "B737-800", "b737 800" and "B737800" are all the same.
+func typeKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return -1
+		}
+	}, s)
+}
+
+var parenPattern = regexp.MustCompile(`\([^)]*\)`)
+
+// modelNumberPattern matches a designator followed by the manufacturer's model
+// number, such as "A320-214", or by a version, such as "B733v2".
+var modelNumberPattern = regexp.MustCompile(`^([A-Z][0-9]{3})(-[0-9]{3}|V[0-9]+)$`)
+
+// addAircraftType adds a line of EuroScope's ICAO_Aircraft.txt, which looks
+// like "B738<TAB>ML2J<TAB>BOEING<TAB>737-800, BBJ2".
+func (d *Database) addAircraftType(designator, description, manufacturer, names string) {
+	if d.AircraftTypes == nil {
+		d.AircraftTypes = make(map[string]string, 3e3)
+	}
+	if d.typeNames == nil {
+		d.typeNames = make(map[string]string, 6e3)
+	}
+
+	d.AircraftTypes[designator] = description
+
+	names = parenPattern.ReplaceAllString(names, "")
+	for _, name := range strings.Split(names, ",") {
+		for _, k := range []string{typeKey(name), typeKey(manufacturer + name)} {
+			if k == "" {
+				continue
+			}
+			if x, ok := d.typeNames[k]; ok && x != designator {
+				d.typeNames[k] = "" // ambiguous
+				continue
+			}
+			d.typeNames[k] = designator
+		}
+	}
+}
+
+// ReadTypeAliases reads a table of free-form aircraft type names and the ICAO
+// type designators they stand for. Each line contains a name followed by the
+// designator, separated by white space. Lines starting with "#" are ignored.
+func (d *Database) ReadTypeAliases(r io.Reader) error {
+	if d.TypeAliases == nil {
+		d.TypeAliases = make(map[string]string)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		alias := typeKey(strings.Join(fields[:len(fields)-1], ""))
+		d.TypeAliases[alias] = fields[len(fields)-1]
+	}
+
+	return scanner.Err()
+}
+
+// NormalizeType returns the ICAO type designator for a free-form aircraft
+// type name such as "B737-800", "Boeing 737-800" or "A320-214". It returns
+// the empty string if the name cannot be resolved unambiguously.
+func (d *Database) NormalizeType(s string) string {
+	k := typeKey(s)
+	if k == "" {
+		return ""
+	}
+
+	if _, ok := d.AircraftTypes[k]; ok || d.TypeCodes[k] {
+		return k
+	}
+	if t := d.TypeAliases[k]; t != "" {
+		return t
+	}
+	if t := d.typeNames[k]; t != "" {
+		return t
+	}
+	// "B737-800" -> "737-800"
+	if k[0] >= 'A' && k[0] <= 'Z' {
+		if t := d.TypeAliases[k[1:]]; t != "" {
+			return t
+		}
+		if t := d.typeNames[k[1:]]; t != "" {
+			return t
+		}
+	}
+	// "A320-214" -> "A320" and "B733v2" -> "B733", but not "B737MAX8" ->
+	// "B737".
+	if m := modelNumberPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s))); m != nil {
+		if _, ok := d.AircraftTypes[m[1]]; ok {
+			return m[1]
+		}
+	}
+
+	return ""
+}
+
+// InferType returns the ICAO type designator of an installed model. It uses
+// vPilot's type code if vPilot knows the model, and otherwise tries atc_model,
+// ui_type, the model folder, and the name of the folder containing
+// aircraft.cfg, in that order.
+func (d *Database) InferType(path string, m InstalledModel) string {
+	if spec, ok := d.ModelSpecs[m.Title]; ok && spec.TypeCode != "" {
+		return spec.TypeCode
+	}
+
+	candidates := []string{
+		m.Model,
+		m.UIType,
+		strings.TrimPrefix(strings.ToLower(m.ModelFolder), "model."),
+	}
+	// "WoA_AIA_B733v2_Winglet"
+	for _, s := range strings.Split(filepath.Base(filepath.Dir(path)), "_") {
+		if len(s) >= 3 {
+			candidates = append(candidates, s)
+		}
+	}
+
+	for _, s := range candidates {
+		if t := d.NormalizeType(s); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// UnresolvedTypes returns the atc_model values of installed models whose type
+// cannot be inferred, sorted by the number of affected models.
+func (idx Index) UnresolvedTypes(d *Database) []string {
+	counts := make(map[string]int)
+	for path, e := range idx {
+		for _, m := range e.Models {
+			if d.InferType(path, m) == "" {
+				counts[m.Model]++
+			}
+		}
+	}
+
+	x := make([]string, 0, len(counts))
+	for s := range counts {
+		x = append(x, s)
+	}
+	sort.Slice(x, func(i, j int) bool {
+		if counts[x[i]] != counts[x[j]] {
+			return counts[x[i]] > counts[x[j]]
+		}
+		return x[i] < x[j]
+	})
+	return x
+}
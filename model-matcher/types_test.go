@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNormalizeType(t *testing.T) {
+	db := &Database{}
+	for _, fname := range []string{"../EuroScope/EDBB/ICAO_Aircraft.txt", "type-aliases.txt"} {
+		f, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fname == "type-aliases.txt" {
+			err = db.ReadTypeAliases(f)
+		} else {
+			err = db.ReadEuroScopeICAO(f)
+		}
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		given, want string
+	}{
+		{"", ""},
+		{"A320", "A320"},
+		{"a320", "A320"},
+		{"A320-214", "A320"},
+		{"A-320", "A320"},
+		{"A300-600", "A306"},
+		{"B737-800", "B738"},
+		{"737-800", "B738"},
+		{"Boeing 737-800", "B738"},
+		{"Boeing 737", "B738"},
+		{"B737MAX8", "B38M"},
+		{"B737-8", "B38M"},
+		{"737-9", "B39M"},
+		{"A321neo", "A21N"},
+		{"A320-214", "A320"},
+		{"A320-200N", ""},
+		{"B737-MAX", ""},
+		{"B747-400", "B744"},
+		{"B777F", "B77L"},
+		{"777-300ER", "B77W"},
+		{"ERJ-145", "E145"},
+		{"Embraer 190", "E190"},
+		{"MD-11", "MD11"},
+		{"Q400", "DH8D"},
+		{"Spitfire Mk IX", "SPIT"},
+		{"Xyzzy Flyer", ""},
+	}
+
+	for _, tc := range cases {
+		if got := db.NormalizeType(tc.given); got != tc.want {
+			t.Errorf("NormalizeType(%q) == %q, want %q", tc.given, got, tc.want)
+		}
+	}
+
+	m := InstalledModel{Model: "AIRBUS", ModelFolder: "model"}
+	if got, want := db.InferType("SimObjects/Airplanes/WoA_AIA_B733v2_Winglet/aircraft.cfg", m), "B733"; got != want {
+		t.Errorf("InferType() == %q, want %q", got, want)
+	}
+}
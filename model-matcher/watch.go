@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// notifier reports that something changed in one of the watched directories.
+// Events are coalesced; receivers have to find out themselves what exactly
+// changed.
+type notifier interface {
+	Add(dir string) error
+	Events() <-chan struct{}
+}
+
+// fileState identifies a version of a model file. The modification time of
+// the directory is included, because the model and texture folders next to
+// the file decide whether a model can be used.
+type fileState struct {
+	Size       int64
+	ModTime    time.Time
+	DirModTime time.Time
+}
+
+func statFile(path string) (fileState, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	di, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{fi.Size(), fi.ModTime(), di.ModTime()}, nil
+}
+
+func (s fileState) Equal(o fileState) bool {
+	return s.Size == o.Size && s.ModTime.Equal(o.ModTime) && s.DirModTime.Equal(o.DirModTime)
+}
+
+// windowsMountPattern matches Windows drives mounted into WSL. inotify doesn't
+// see changes made to them by Windows programs.
+var windowsMountPattern = regexp.MustCompile(`^/mnt/[a-zA-Z](/|$)`)
+
+// WatchModels sends the paths of aircraft.cfg files below root, and of
+// xsb_aircraft.txt files in cslDirs, that have been created, modified or
+// removed, until ctx is canceled. It uses inotify where available and falls
+// back to polling every interval otherwise, and for directories on Windows
+// drives.
+func WatchModels(ctx context.Context, root string, cslDirs []string, interval time.Duration, changed chan<- []string) {
+	dirs := append([]string{filepath.Join(root, "SimObjects/Airplanes")}, cslDirs...)
+	polled := false
+	for _, dir := range dirs {
+		polled = polled || windowsMountPattern.MatchString(dir)
+	}
+
+	var n notifier
+	if !polled {
+		var err error
+		if n, err = newNotifier(ctx); err != nil {
+			slog.Warn("Cannot watch SimObjects, polling instead", "err", err)
+			n = nil
+			polled = true
+		}
+	}
+
+	addWatches := func(state map[string]fileState) {
+		if n == nil {
+			return
+		}
+		for _, dir := range dirs {
+			if err := n.Add(dir); err != nil {
+				slog.Warn("Cannot watch directory", "err", err)
+			}
+		}
+		for path := range state {
+			if err := n.Add(filepath.Dir(path)); err != nil {
+				slog.Warn("Cannot watch directory", "err", err)
+			}
+		}
+	}
+
+	state := statModels(root, cslDirs)
+	addWatches(state)
+
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+
+	var events <-chan struct{}
+	if n != nil {
+		events = n.Events()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			if !polled {
+				continue
+			}
+		case <-events:
+			// Installers write many files at once. Wait until they are done.
+			for quiet := false; !quiet; {
+				select {
+				case <-ctx.Done():
+					return
+				case <-events:
+				case <-time.After(2 * time.Second):
+					quiet = true
+				}
+			}
+		}
+
+		next := statModels(root, cslDirs)
+		if paths := diffStates(state, next); len(paths) > 0 {
+			select {
+			case changed <- paths:
+			case <-ctx.Done():
+				return
+			}
+		}
+		state = next
+		addWatches(state)
+	}
+}
+
+func statModels(root string, cslDirs []string) map[string]fileState {
+	paths, err := ModelPaths(root)
+	if err != nil {
+		slog.Warn("Cannot list models", "err", err)
+		return nil
+	}
+	csl, err := CSLPaths(cslDirs)
+	if err != nil {
+		slog.Warn("Cannot list CSL packages", "err", err)
+		return nil
+	}
+	paths = append(paths, csl...)
+
+	state := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		s, err := statFile(path)
+		if err != nil {
+			continue
+		}
+		state[path] = s
+	}
+
+	return state
+}
+
+func diffStates(old, new map[string]fileState) []string {
+	var paths []string
+	for path, s := range new {
+		if o, ok := old[path]; !ok || !o.Equal(s) {
+			paths = append(paths, path)
+		}
+	}
+	for path := range old {
+		if _, ok := new[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+type inotify struct {
+	fd     int
+	f      *os.File // fd, for reads that can be interrupted by Close
+	events chan struct{}
+
+	mu      sync.Mutex
+	watched map[string]bool  // by directory
+	wds     map[int32]string // watch descriptor to directory
+}
+
+func newNotifier(ctx context.Context) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &inotify{
+		fd:      fd,
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan struct{}, 1),
+		watched: make(map[string]bool),
+		wds:     make(map[int32]string),
+	}
+	go n.read()
+	go func() {
+		<-ctx.Done()
+		n.f.Close()
+	}()
+
+	return n, nil
+}
+
+func (n *inotify) Add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.watched[dir] {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	n.watched[dir] = true
+	n.wds[int32(wd)] = dir
+	return nil
+}
+
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+func (n *inotify) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		m, err := n.f.Read(buf)
+		if err != nil {
+			slog.Debug("inotify stopped", "err", err)
+			return
+		}
+
+		// We don't care about the individual events (the watcher rescans
+		// anyway), except that the watches of removed directories are
+		// gone, so they have to be watched again if they are recreated.
+		n.mu.Lock()
+		for i := 0; i+syscall.SizeofInotifyEvent <= m; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[i]))
+			if e.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.watched, n.wds[e.Wd])
+				delete(n.wds, e.Wd)
+			}
+			i += syscall.SizeofInotifyEvent + int(e.Len)
+		}
+		n.mu.Unlock()
+
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
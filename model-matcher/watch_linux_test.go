@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInotifyWatched(t *testing.T) {
+	root, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nn, err := newNotifier(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := nn.(*inotify)
+
+	dir := filepath.Join(root, "WoA_DLH_A320")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{root, dir} {
+		if err := n.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watched := func(d string) bool {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		return n.watched[d]
+	}
+	wait := func() {
+		select {
+		case <-n.Events():
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "aircraft.cfg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+	if !watched(root) || !watched(dir) {
+		t.Errorf("watches dropped after an event in %s", dir)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Events are coalesced, so wait for the watch to be dropped instead.
+	for deadline := time.Now().Add(5 * time.Second); watched(dir) && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if watched(dir) {
+		t.Errorf("%s still watched after removal", dir)
+	}
+	if !watched(root) {
+		t.Errorf("%s not watched anymore", root)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+func newNotifier(ctx context.Context) (notifier, error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDiffStates(t *testing.T) {
+	t0 := time.Unix(1500000000, 0)
+	old := map[string]fileState{
+		"unchanged": {100, t0, t0},
+		"resized":   {100, t0, t0},
+		"touched":   {100, t0, t0},
+		"folders":   {100, t0, t0},
+		"removed":   {100, t0, t0},
+	}
+	new := map[string]fileState{
+		"unchanged": {100, t0, t0},
+		"resized":   {200, t0, t0},
+		"touched":   {100, t0.Add(time.Second), t0},
+		"folders":   {100, t0, t0.Add(time.Second)},
+		"created":   {100, t0, t0},
+	}
+
+	got := diffStates(old, new)
+	sort.Strings(got)
+	want := []string{"created", "folders", "removed", "resized", "touched"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffStates() == %v, want %v", got, want)
+	}
+}
+
+func TestStatModels(t *testing.T) {
+	root, err := ioutil.TempDir("", "model-matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	paths := []string{
+		filepath.Join(root, "SimObjects/Airplanes/WoA_DLH_A320/aircraft.cfg"),
+		filepath.Join(root, "CSL/BB_A320/xsb_aircraft.txt"),
+	}
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := statModels(root, []string{filepath.Join(root, "CSL")})
+	got := make([]string, 0, len(state))
+	for path := range state {
+		got = append(got, path)
+	}
+	sort.Strings(got)
+	sort.Strings(paths)
+	if !reflect.DeepEqual(got, paths) {
+		t.Errorf("statModels() == %v, want %v", got, paths)
+	}
+}
+
+func TestWindowsMountPattern(t *testing.T) {
+	cases := []struct {
+		given string
+		want  bool
+	}{
+		{"/mnt/c/Program Files (x86)/Steam/steamapps/common/FSX", true},
+		{"/mnt/d", true},
+		{"/mnt/data/FSX", false},
+		{"/home/fsx", false},
+	}
+
+	for _, tc := range cases {
+		if got := windowsMountPattern.MatchString(tc.given); got != tc.want {
+			t.Errorf("windowsMountPattern.MatchString(%q) == %v, want %v", tc.given, got, tc.want)
+		}
+	}
+}
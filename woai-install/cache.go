@@ -0,0 +1,214 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheDir is where fetch caches responses.
+const cacheDir = ".cache"
+
+// pageTTL is how long web pages are cached. Packages don't change once
+// uploaded, so they are cached until removed explicitly.
+var pageTTL = 24 * time.Hour
+
+// cacheFile returns the name of the file that caches the response to req.
+func cacheFile(req *http.Request) string {
+	fname := filepath.Join(cacheDir, req.Method, req.URL.String())
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '?':
+			return '_'
+		default:
+			return r
+		}
+	}, fname)
+}
+
+// isPackage tells whether s, a URL or the name of a cache file, refers to a
+// package download.
+func isPackage(s string) bool {
+	return strings.Contains(s, "sendfile.php")
+}
+
+// expired tells whether the cached response for the URL or cache file s,
+// last modified at mtime, has to be fetched again.
+func expired(s string, mtime time.Time) bool {
+	return !isPackage(s) && time.Since(mtime) > pageTTL
+}
+
+// isPartial tells whether fname is the temporary file of a download in
+// progress or an interrupted one; see TeeFileReader.
+func isPartial(fname string) bool {
+	return strings.HasPrefix(filepath.Base(fname), ".")
+}
+
+type cacheEntry struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// cacheEntries returns the files in the cache, oldest first.
+func cacheEntries(dir string) ([]cacheEntry, error) {
+	var es []cacheEntry
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		es = append(es, cacheEntry{path, fi.Size(), fi.ModTime()})
+		return nil
+	})
+
+	sort.Slice(es, func(i, j int) bool { return es[i].ModTime.Before(es[j].ModTime) })
+	return es, err
+}
+
+// verify returns an error if a cache entry is not usable. Partial downloads
+// are fine, since they are resumed, but not validators without one.
+func (e cacheEntry) verify() error {
+	switch {
+	case isPartial(e.Path):
+		part := strings.TrimSuffix(e.Path, ".validator")
+		if part == e.Path {
+			return nil
+		}
+		if _, err := os.Stat(part); os.IsNotExist(err) {
+			return errors.New("validator without partial download")
+		}
+		return nil
+	case e.Size == 0:
+		return errors.New("empty")
+	case isPackage(e.Path):
+		z, err := zip.OpenReader(e.Path)
+		if err != nil {
+			return err
+		}
+		return z.Close()
+	}
+	return nil
+}
+
+func (e cacheEntry) status() string {
+	switch {
+	case isPartial(e.Path):
+		return "partial"
+	case isPackage(e.Path):
+		return "package"
+	case expired(e.Path, e.ModTime):
+		return "expired"
+	}
+	return "page"
+}
+
+// cacheCommand implements the cache subcommands.
+func cacheCommand(w io.Writer, dir string, args []string) error {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	remove := fs.Bool("remove", false, "verify: Remove broken entries.")
+	maxAge := fs.Duration("age", 0, "prune: Remove entries older than this.")
+	maxSize := fs.Int64("size", 0, "prune: Remove the oldest entries until the cache is at most this many MB.")
+	pages := fs.Bool("pages", false, "clear: Remove web pages only, keep packages.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s cache list|verify|prune|clear [FLAGS]\n\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	if len(args) < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	cmd := args[0]
+	fs.Parse(args[1:])
+
+	es, err := cacheEntries(dir)
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "list":
+		var total int64
+		for _, e := range es {
+			fmt.Fprintf(w, "%s\t%s\t%7.1f MB\t%s\n", e.ModTime.Format("2006-01-02 15:04"), e.status(), mb(e.Size), e.Path)
+			total += e.Size
+		}
+		fmt.Fprintf(w, "%d entries, %.1f MB\n", len(es), mb(total))
+
+	case "verify":
+		for _, e := range es {
+			err := e.verify()
+			if err == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s: %v\n", e.Path, err)
+			if *remove {
+				if err := os.Remove(e.Path); err != nil {
+					return err
+				}
+			}
+		}
+
+	case "prune":
+		var total int64
+		for _, e := range es {
+			total += e.Size
+		}
+		for _, e := range es {
+			switch {
+			case *maxAge > 0 && time.Since(e.ModTime) > *maxAge:
+			case *maxSize > 0 && total > *maxSize<<20:
+			default:
+				continue
+			}
+			err := os.Remove(e.Path)
+			switch {
+			case os.IsNotExist(err):
+				// A validator, removed with its partial download.
+				continue
+			case err != nil:
+				return err
+			}
+			fmt.Fprintln(w, "removing", e.Path)
+			total -= e.Size
+
+			if isPartial(e.Path) {
+				// Without the partial download, its validator is useless.
+				v := validatorFile(e.Path)
+				if fi, err := os.Stat(v); err == nil && os.Remove(v) == nil {
+					total -= fi.Size()
+				}
+			}
+		}
+
+	case "clear":
+		for _, e := range es {
+			if *pages && isPackage(e.Path) {
+				continue
+			}
+			if err := os.Remove(e.Path); err != nil {
+				return err
+			}
+		}
+
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func mb(n int64) float64 {
+	return float64(n) / (1 << 20)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachePrune(t *testing.T) {
+	// Cache entries by name, with their size in MB and age in days.
+	entries := map[string]struct {
+		size int
+		age  int
+	}{
+		"GET/http___www.world-of-ai.com_allpackages.php":                   {1, 1},
+		"GET/http___library.avsim.net_sendfile.php_DLID=1":                 {3, 30},
+		"GET/http___library.avsim.net_sendfile.php_DLID=2":                 {3, 5},
+		"GET/.http___library.avsim.net_sendfile.php_DLID=3.part":           {1, 2},
+		"GET/.http___library.avsim.net_sendfile.php_DLID=3.part.validator": {0, 2},
+	}
+
+	testCases := []struct {
+		name string
+		args []string
+		want []string // remaining entries
+	}{
+		{"nothing", []string{"prune"}, []string{
+			"GET/.http___library.avsim.net_sendfile.php_DLID=3.part",
+			"GET/.http___library.avsim.net_sendfile.php_DLID=3.part.validator",
+			"GET/http___library.avsim.net_sendfile.php_DLID=1",
+			"GET/http___library.avsim.net_sendfile.php_DLID=2",
+			"GET/http___www.world-of-ai.com_allpackages.php",
+		}},
+		{"age", []string{"prune", "-age", "240h"}, []string{
+			"GET/.http___library.avsim.net_sendfile.php_DLID=3.part",
+			"GET/.http___library.avsim.net_sendfile.php_DLID=3.part.validator",
+			"GET/http___library.avsim.net_sendfile.php_DLID=2",
+			"GET/http___www.world-of-ai.com_allpackages.php",
+		}},
+		{"age partial", []string{"prune", "-age", "36h"}, []string{
+			"GET/http___www.world-of-ai.com_allpackages.php",
+		}},
+		{"size", []string{"prune", "-size", "4"}, []string{
+			"GET/.http___library.avsim.net_sendfile.php_DLID=3.part",
+			"GET/.http___library.avsim.net_sendfile.php_DLID=3.part.validator",
+			"GET/http___www.world-of-ai.com_allpackages.php",
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "woai-install")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, e := range entries {
+				fname := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(fname, make([]byte, e.size<<20), 0644); err != nil {
+					t.Fatal(err)
+				}
+				mtime := time.Now().Add(-time.Duration(e.age)*24*time.Hour - time.Minute)
+				if err := os.Chtimes(fname, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := cacheCommand(&buf, dir, tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			es, err := cacheEntries(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range es {
+				rel, _ := filepath.Rel(dir, e.Path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("remaining entries == %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCacheVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woai-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Cache entries by name, and whether they are broken.
+	entries := map[string]bool{
+		"GET/http___www.world-of-ai.com_allpackages.php":                   false,
+		"GET/http___www.world-of-ai.com_empty.php":                         true,
+		"GET/.http___library.avsim.net_sendfile.php_DLID=1.part":           false,
+		"GET/.http___library.avsim.net_sendfile.php_DLID=1.part.validator": false,
+		"GET/.http___library.avsim.net_sendfile.php_DLID=2.part":           false,
+		"GET/.http___library.avsim.net_sendfile.php_DLID=3.part.validator": true,
+	}
+	var want []string
+	for name, broken := range entries {
+		fname := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := []byte("content")
+		if strings.Contains(name, "empty") {
+			content = nil
+		}
+		if err := ioutil.WriteFile(fname, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !broken {
+			want = append(want, name)
+		}
+	}
+	sort.Strings(want)
+
+	var buf bytes.Buffer
+	if err := cacheCommand(&buf, dir, []string{"verify", "-remove"}); err != nil {
+		t.Fatal(err)
+	}
+
+	es, err := cacheEntries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range es {
+		rel, _ := filepath.Rel(dir, e.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining entries == %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Conflict policies for files that exist with different content. There is
+// no policy to keep both files: FSX only loads a texture, effect or scenery
+// file under the name that aircraft.cfg and the other files refer to, so a
+// renamed copy would never be used.
+const (
+	conflictSkip      = "skip"      // keep the existing file
+	conflictOverwrite = "overwrite" // back up and replace the existing file
+)
+
+var conflictPolicies = map[string]bool{
+	conflictSkip:      true,
+	conflictOverwrite: true,
+}
+
+// A Conflict records a file of a package that existed already with different
+// content.
+type Conflict struct {
+	Path   string // relative to FSXRoot, with forward slashes
+	Owner  string `json:",omitempty"` // DLID of the package that installed the existing file
+	Policy string
+	Backup bool `json:",omitempty"` // the existing file was saved to backupFile
+}
+
+// backupFile returns where the file rel that the package dlID overwrote is
+// kept until the package is uninstalled, below the manifest directory dir.
+func backupFile(dir, dlID, rel string) string {
+	return filepath.Join(dir, "backups", dlID, filepath.FromSlash(rel))
+}
+
+// copyFile copies the file src to dst, creating the directory of dst.
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// fileOwners returns the manifests of the installed packages by the paths
+// of their files. If several packages have the same file, the one installed
+// last wins.
+func fileOwners(dir string) (map[string]*Manifest, error) {
+	ms, err := LoadManifests(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]*Manifest)
+	for _, m := range ms {
+		for _, f := range m.Files {
+			if o := owners[f.Path]; o == nil || o.Installed.Before(m.Installed) {
+				owners[f.Path] = m
+			}
+		}
+	}
+	return owners, nil
+}
+
+// conflict reports that dest exists with different content, owned by the
+// package owner or by no known package if owner is nil, and records it in
+// the manifest. It returns dest if the file is to be overwritten according
+// to the policy, after backing it up, or the empty string if it is skipped.
+func (in *installer) conflict(dest string, owner *Manifest) (string, error) {
+	c := Conflict{Path: filepath.ToSlash(dest), Policy: in.policy}
+	desc := "unknown package"
+	if owner != nil {
+		c.Owner = owner.DLID
+		desc = fmt.Sprintf("%s (%s)", owner.Title, owner.DLID)
+	}
+
+	switch in.policy {
+	case conflictSkip:
+		dest = ""
+	case conflictOverwrite:
+		backup := backupFile(in.dir, in.m.DLID, c.Path)
+		if err := copyFile(backup, filepath.Join(FSXRoot, dest)); err != nil {
+			return "", fmt.Errorf("Cannot back up %s: %v", dest, err)
+		}
+		c.Backup = true
+	}
+
+	fmt.Printf("\t conflict: %s from %s: %s\n", filepath.Join(FSXRoot, c.Path), desc, in.policy)
+	in.m.Conflicts = append(in.m.Conflicts, c)
+	return dest, nil
+}
+
+// conflicts tells whether fname exists with content different from zf.
+func conflicts(zf *zip.File, fname string) bool {
+	sum, err := fileSHA1(fname)
+	if err != nil {
+		return false
+	}
+
+	r, err := zf.Open()
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) != sum
+}
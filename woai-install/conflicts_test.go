@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractConflicts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woai-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(root string) { FSXRoot = root }(FSXRoot)
+	FSXRoot = dir
+
+	const (
+		owned   = "Effects/Texture/woai_owned.bmp"
+		unknown = "Effects/Texture/woai_unknown.bmp"
+		same    = "Effects/Texture/woai_same.bmp"
+	)
+	other := &Manifest{DLID: "67890", Title: "WoAI Condor"}
+
+	testCases := []struct {
+		policy        string
+		wantContent   map[string]string
+		wantConflicts []Conflict
+	}{
+		{
+			policy: conflictSkip,
+			wantContent: map[string]string{
+				owned:   "old",
+				unknown: "old",
+				same:    "same",
+			},
+			wantConflicts: []Conflict{
+				{Path: owned, Owner: "67890", Policy: conflictSkip},
+				{Path: unknown, Policy: conflictSkip},
+			},
+		},
+		{
+			policy: conflictOverwrite,
+			wantContent: map[string]string{
+				owned:   "new",
+				unknown: "new",
+				same:    "same",
+			},
+			wantConflicts: []Conflict{
+				{Path: owned, Owner: "67890", Policy: conflictOverwrite, Backup: true},
+				{Path: unknown, Policy: conflictOverwrite, Backup: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			for path, content := range map[string]string{owned: "old", unknown: "old", same: "same"} {
+				fname := filepath.Join(FSXRoot, filepath.FromSlash(path))
+				if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(fname, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			in := &installer{
+				m:       &Manifest{DLID: "12345", Title: "WoAI Lufthansa"},
+				created: make(map[string]bool),
+				owners:  map[string]*Manifest{owned: other, same: other},
+				policy:  tc.policy,
+				dir:     filepath.Join(dir, manifestDir),
+			}
+			for _, x := range []struct{ path, content string }{
+				{owned, "new"},
+				{unknown, "new"},
+				{same, "same"},
+			} {
+				if err := in.extract(x.path, strings.NewReader(x.content)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for path, want := range tc.wantContent {
+				b, err := ioutil.ReadFile(filepath.Join(FSXRoot, filepath.FromSlash(path)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("%s == %q, want %q", path, b, want)
+				}
+			}
+			for _, c := range in.m.Conflicts {
+				b, err := ioutil.ReadFile(backupFile(in.dir, in.m.DLID, c.Path))
+				switch {
+				case !c.Backup && !os.IsNotExist(err):
+					t.Errorf("%s: backup exists, err == %v", c.Path, err)
+				case c.Backup && string(b) != "old":
+					t.Errorf("%s: backup == %q, want \"old\"; err == %v", c.Path, b, err)
+				}
+			}
+			if !reflect.DeepEqual(in.m.Conflicts, tc.wantConflicts) {
+				t.Errorf("conflicts == %+v, want %+v", in.m.Conflicts, tc.wantConflicts)
+			}
+
+			// Skipped files don't belong to the package.
+			var files []string
+			for _, f := range in.m.Files {
+				files = append(files, f.Path)
+			}
+			wantFiles := []string{same}
+			if tc.policy == conflictOverwrite {
+				wantFiles = []string{owned, unknown, same}
+			}
+			if !reflect.DeepEqual(files, wantFiles) {
+				t.Errorf("manifest files == %v, want %v", files, wantFiles)
+			}
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woai-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("effects/texture/woai_light.bmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("light"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		content string // of the existing file; empty if there is none
+		want    bool
+	}{
+		{"missing", "", false},
+		{"same", "light", false},
+		{"different", "dark", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fname := filepath.Join(dir, tc.name+".bmp")
+			if tc.content != "" {
+				if err := ioutil.WriteFile(fname, []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := conflicts(z.File[0], fname); got != tc.want {
+				t.Errorf("conflicts() == %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partialFile returns the name of the file that fname is downloaded to. It
+// is kept if the download is interrupted, so that it can be resumed.
+func partialFile(fname string) string {
+	return filepath.Join(filepath.Dir(fname), "."+filepath.Base(fname)+".part")
+}
+
+// validatorFile returns the name of the file that keeps the ETag or
+// Last-Modified header of the response that part is downloaded from.
+func validatorFile(part string) string {
+	return part + ".validator"
+}
+
+// validator returns the value for an If-Range header that makes the server
+// send the rest of the same file only: a strong ETag, or else Last-Modified.
+// It is empty if the response has neither.
+func validator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// downloadFile streams the response to req into fname. An interrupted
+// download is resumed with a range request, if the server identified the file
+// when it started, so that a file that changed in the meantime is downloaded
+// again instead of appended to. fname appears only once the download is
+// complete.
+func downloadFile(req *http.Request, fname, label string) error {
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+
+	part := partialFile(fname)
+	vfile := validatorFile(part)
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		if v, err := ioutil.ReadFile(vfile); err == nil && len(v) > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", string(v))
+		}
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	total := int64(-1)
+	switch res.StatusCode {
+	case http.StatusOK:
+		// No resume; start over.
+		if offset, err = f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		total = res.ContentLength
+		if err := ioutil.WriteFile(vfile, []byte(validator(res)), 0644); err != nil {
+			return err
+		}
+	case http.StatusPartialContent:
+		var start int64
+		start, total = contentRange(res.Header.Get("Content-Range"))
+		if start != offset {
+			os.Remove(part)
+			os.Remove(vfile)
+			return fmt.Errorf("Server resumed at byte %d instead of %d", start, offset)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is bogus, or the file has changed.
+		os.Remove(part)
+		os.Remove(vfile)
+		return errors.New(res.Status)
+	default:
+		return errors.New(res.Status)
+	}
+
+	p := &progress{label: label, n: offset, total: total, printed: time.Now()}
+	if _, err := io.Copy(f, io.TeeReader(res.Body, p)); err != nil {
+		return err
+	}
+	p.done()
+
+	if total >= 0 && p.n != total {
+		return fmt.Errorf("Incomplete download: %d of %d bytes", p.n, total)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(part, fname); err != nil {
+		return err
+	}
+	os.Remove(vfile)
+	return nil
+}
+
+// contentRange returns the first byte and the complete length from a
+// Content-Range header such as "bytes 100-199/200". Either is -1 if it is
+// unknown.
+func contentRange(s string) (start, total int64) {
+	start, total = -1, -1
+	s = strings.TrimPrefix(s, "bytes ")
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return start, total
+	}
+	if n, err := strconv.ParseInt(s[i+1:], 10, 64); err == nil {
+		total = n
+	}
+	if j := strings.Index(s[:i], "-"); j >= 0 {
+		if n, err := strconv.ParseInt(s[:j], 10, 64); err == nil {
+			start = n
+		}
+	}
+	return start, total
+}
+
+// progress prints the progress of a download every few seconds.
+type progress struct {
+	label   string
+	n       int64
+	total   int64
+	printed time.Time
+	shown   bool
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.n += int64(len(b))
+	if time.Since(p.printed) > 5*time.Second {
+		p.print()
+	}
+	return len(b), nil
+}
+
+func (p *progress) print() {
+	p.printed = time.Now()
+	p.shown = true
+	if p.total > 0 {
+		fmt.Printf("\t%s: %.1f of %.1f MB\n", p.label, mb(p.n), mb(p.total))
+	} else {
+		fmt.Printf("\t%s: %.1f MB\n", p.label, mb(p.n))
+	}
+}
+
+// done prints the final size, unless the download was too quick to print
+// any progress.
+func (p *progress) done() {
+	if p.shown {
+		p.print()
+	}
+}
+
+// A download is the result of downloading the package of a title.
+type download struct {
+	title string
+	dlID  string
+	fname string
+	err   error
+}
+
+// downloadAll downloads the packages with n parallel workers. The results
+// are delivered in no particular order; the channel is closed when all
+// downloads are done.
+func downloadAll(ps []*Package, n int) <-chan download {
+	if n < 1 {
+		n = 1
+	}
+
+	todo := make(chan *Package)
+	go func() {
+		for _, p := range ps {
+			todo <- p
+		}
+		close(todo)
+	}()
+
+	results := make(chan download)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range todo {
+				results <- downloadTitle(p)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// downloadTitle tries the download pages of a package until one of them
+// yields a usable package.
+func downloadTitle(p *Package) download {
+	d := download{title: p.Title, err: errors.New("No download link")}
+	for _, link := range p.Links {
+		d.fname, d.dlID, d.err = downloadPackage(link, p.Title)
+		if d.err != nil {
+			continue
+		}
+		if _, _, d.err = openPackage(d.fname, d.dlID); d.err == nil {
+			break
+		}
+		os.Remove(d.fname) // don't serve the broken file from the cache
+	}
+	return d
+}
+
+var fileLocks = struct {
+	sync.Mutex
+	m map[string]*sync.Mutex
+}{m: make(map[string]*sync.Mutex)}
+
+// lockFile serializes downloads to fname, for instance if two titles link to
+// the same package. It returns the function that releases the lock.
+func lockFile(fname string) func() {
+	fileLocks.Lock()
+	mu := fileLocks.m[fname]
+	if mu == nil {
+		mu = &sync.Mutex{}
+		fileLocks.m[fname] = mu
+	}
+	fileLocks.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
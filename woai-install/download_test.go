@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadFile(t *testing.T) {
+	const content = "0123456789abcdefghij"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	})
+	mux.HandleFunc("/bad-range", func(w http.ResponseWriter, r *http.Request) {
+		// Resumes at the wrong offset.
+		w.Header().Set("Content-Range", "bytes 0-19/20")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	testCases := []struct {
+		name      string
+		path      string
+		part      string // content of the partial file; none if empty
+		validator string // content of the validator file; none if empty
+		want      string // downloaded content; empty for an error
+	}{
+		{"fresh", "/file", "", "", content},
+		// The partial file is made up, so that the result shows whether
+		// the download was resumed.
+		{"resumed", "/file", "XXXXX", `"v2"`, "XXXXX" + content[5:]},
+		{"changed", "/file", "XXXXX", `"v1"`, content},
+		{"no validator", "/file", "XXXXX", "", content},
+		{"wrong offset", "/bad-range", "XXXXX", `"v2"`, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "woai-install")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			fname := filepath.Join(dir, "package.zip")
+			part := partialFile(fname)
+			if tc.part != "" {
+				if err := ioutil.WriteFile(part, []byte(tc.part), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.validator != "" {
+				if err := ioutil.WriteFile(validatorFile(part), []byte(tc.validator), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req, err := http.NewRequest("GET", ts.URL+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = downloadFile(req, fname, tc.name)
+			if tc.want == "" {
+				if err == nil {
+					t.Fatal("downloadFile() succeeded")
+				}
+				if _, err := os.Stat(part); !os.IsNotExist(err) {
+					t.Errorf("partial file kept: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadFile(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.want {
+				t.Errorf("downloaded %q, want %q", b, tc.want)
+			}
+			for _, f := range []string{part, validatorFile(part)} {
+				if _, err := os.Stat(f); !os.IsNotExist(err) {
+					t.Errorf("%s kept: %v", filepath.Base(f), err)
+				}
+			}
+		})
+	}
+}
+
+func TestContentRange(t *testing.T) {
+	testCases := []struct {
+		s                    string
+		wantStart, wantTotal int64
+	}{
+		{"bytes 100-199/200", 100, 200},
+		{"bytes 0-99/*", 0, -1},
+		{"bytes */200", -1, 200},
+		{"", -1, -1},
+	}
+
+	for _, tc := range testCases {
+		start, total := contentRange(tc.s)
+		if start != tc.wantStart || total != tc.wantTotal {
+			t.Errorf("contentRange(%q) == %d, %d, want %d, %d", tc.s, start, total, tc.wantStart, tc.wantTotal)
+		}
+	}
+}
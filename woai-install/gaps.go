@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Wanted is a combination of airline and aircraft type that should have a
+// model, such as one seen on VATSIM.
+type Wanted struct {
+	Airline string // ICAO code
+	Type    string // ICAO type designator
+}
+
+func (w Wanted) String() string {
+	return w.Airline + " " + w.Type
+}
+
+// readWanted reads the wanted combinations from fname, which is either
+// model-matcher's icao.json or a CSV file with the airline and the type in the
+// first two columns, such as the output of model-matcher coverage -missing.
+// The result is sorted and free of duplicates.
+func readWanted(fname string) ([]Wanted, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(fname), ".json") {
+		return readWantedJSON(f)
+	}
+	return readWantedCSV(f)
+}
+
+// readWantedJSON reads icao.json, which maps airlines to the aircraft from
+// their flight plans, such as "H/B744/L".
+func readWantedJSON(r io.Reader) ([]Wanted, error) {
+	var db map[string][]string
+	if err := json.NewDecoder(r).Decode(&db); err != nil {
+		return nil, err
+	}
+
+	var ws []Wanted
+	for al, acs := range db {
+		for _, ac := range acs {
+			ws = append(ws, Wanted{al, equipmentType(ac)})
+		}
+	}
+	return uniqueWanted(ws), nil
+}
+
+// readWantedCSV reads lines like "DLH,B744". Blank lines, lines starting with
+// "#", and a header row are skipped.
+func readWantedCSV(r io.Reader) ([]Wanted, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var ws []Wanted
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("%q: want airline and type", strings.Join(rec, ","))
+		}
+		if len(ws) == 0 && strings.EqualFold(rec[0], "airline") {
+			continue
+		}
+		ws = append(ws, Wanted{rec[0], equipmentType(rec[1])})
+	}
+	return uniqueWanted(ws), nil
+}
+
+// equipmentType returns the type designator in a flight plan's aircraft
+// field, such as B744 in "H/B744/L".
+func equipmentType(s string) string {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) > 1 && len(parts[0]) == 1 {
+		return strings.ToUpper(parts[1])
+	}
+	return strings.ToUpper(parts[0])
+}
+
+func uniqueWanted(ws []Wanted) []Wanted {
+	seen := make(map[Wanted]bool)
+	var unique []Wanted
+	for _, w := range ws {
+		w.Airline = strings.ToUpper(strings.TrimSpace(w.Airline))
+		if w.Airline == "" || w.Type == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		unique = append(unique, w)
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].String() < unique[j].String() })
+	return unique
+}
+
+// covers tells whether p has a model for w.
+func (p *Package) covers(w Wanted) bool {
+	return Query{Airline: w.Airline, Type: w.Type}.Match(p)
+}
+
+// selectPackages picks the packages that cover the wanted combinations that
+// none of the installed packages (by title) covers yet. It prefers packages
+// that cover many combinations, so that no package is picked that only
+// covers what others already do. missing are the combinations no package
+// covers.
+func selectPackages(ps []*Package, ws []Wanted, installed map[string]bool) (selected []*Package, missing []Wanted) {
+	gaps := make(map[Wanted]bool)
+	for _, w := range ws {
+		gaps[w] = true
+	}
+	for _, p := range ps {
+		if !installed[p.Title] {
+			continue
+		}
+		for w := range gaps {
+			if p.covers(w) {
+				delete(gaps, w)
+			}
+		}
+	}
+
+	for {
+		var best *Package
+		n := 0
+		for _, p := range ps {
+			if installed[p.Title] {
+				continue
+			}
+			m := 0
+			for w := range gaps {
+				if p.covers(w) {
+					m++
+				}
+			}
+			if m > n {
+				best, n = p, m
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		selected = append(selected, best)
+		installed[best.Title] = true
+		for w := range gaps {
+			if best.covers(w) {
+				delete(gaps, w)
+			}
+		}
+	}
+
+	for _, w := range ws {
+		if gaps[w] {
+			missing = append(missing, w)
+		}
+	}
+	return selected, missing
+}
+
+// fillGaps selects the packages for the wanted combinations in fname, see
+// selectPackages. Combinations without a package are printed to w.
+//
+// Only packages installed by us count as installed; models from other
+// sources are unknown here. Use a list of the combinations without any model,
+// as written by model-matcher coverage -missing, to leave those alone.
+func fillGaps(w io.Writer, fname string, icao *ICAO) ([]*Package, error) {
+	ws, err := readWanted(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := LoadManifests(manifestDir)
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]bool, len(ms))
+	for _, m := range ms {
+		installed[m.Title] = true
+	}
+
+	ps, err := search(Query{}, icao)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, missing := selectPackages(ps, ws, installed)
+	for _, x := range missing {
+		fmt.Fprintf(w, "%s\tno package\n", x)
+	}
+	return selected, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadWanted(t *testing.T) {
+	want := []Wanted{{"DLH", "B744"}, {"DLH", "CRJ9"}, {"GEC", "MD11"}}
+
+	json := `{"DLH": ["H/B744/L", "CRJ9/L", "B744"], "GEC": ["H/MD11/X"]}`
+	got, err := readWantedJSON(strings.NewReader(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readWantedJSON == %v, want %v", got, want)
+	}
+
+	csv := "airline,type\n# comment\ngec,MD11\nDLH, H/B744/L\nDLH,CRJ9,12\n"
+	got, err = readWantedCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readWantedCSV == %v, want %v", got, want)
+	}
+
+	if _, err := readWantedCSV(strings.NewReader("DLH\n")); err == nil {
+		t.Errorf("readWantedCSV of a line without type: no error")
+	}
+}
+
+func TestSelectPackages(t *testing.T) {
+	var (
+		b733     = &Package{Title: "Lufthansa Boeing 737-300", Airline: "DLH", Types: []string{"B733"}}
+		b733b735 = &Package{Title: "Lufthansa Boeing 737-300/500", Airline: "DLH", Types: []string{"B733", "B735"}}
+		b744     = &Package{Title: "Lufthansa Boeing 747-400", Airline: "DLH", Types: []string{"B744"}}
+		b753     = &Package{Title: "Condor Boeing 757-300", Airline: "CFG", Types: []string{"B753"}}
+		packages = []*Package{b733, b733b735, b744, b753}
+	)
+
+	testCases := []struct {
+		name         string
+		wanted       []Wanted
+		installed    []string
+		wantSelected []*Package
+		wantMissing  []Wanted
+	}{
+		{"nothing wanted", nil, nil, nil, nil},
+		{"fewest packages", []Wanted{{"DLH", "B733"}, {"DLH", "B735"}}, nil, []*Package{b733b735}, nil},
+		{"installed", []Wanted{{"DLH", "B733"}, {"DLH", "B744"}}, []string{b733.Title}, []*Package{b744}, nil},
+		{"missing", []Wanted{{"CFG", "B738"}, {"DLH", "A320"}, {"DLH", "B744"}}, nil, []*Package{b744},
+			[]Wanted{{"CFG", "B738"}, {"DLH", "A320"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			installed := make(map[string]bool)
+			for _, title := range tc.installed {
+				installed[title] = true
+			}
+			selected, missing := selectPackages(packages, tc.wanted, installed)
+			if !reflect.DeepEqual(selected, tc.wantSelected) {
+				t.Errorf("selected == %v, want %v", selected, tc.wantSelected)
+			}
+			if !reflect.DeepEqual(missing, tc.wantMissing) {
+				t.Errorf("missing == %v, want %v", missing, tc.wantMissing)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ICAO resolves the airline and aircraft type names in package titles to
+// ICAO codes, using EuroScope's ICAO_Airlines.txt and ICAO_Aircraft.txt.
+type ICAO struct {
+	airlines map[string]string // upper-case name or callsign -> code
+	types    map[string]string // typeKey of a type name -> designator
+}
+
+// loadICAO reads ICAO_Airlines.txt and ICAO_Aircraft.txt from dir. Missing
+// files are logged; the codes are not resolved then.
+func loadICAO(dir string) *ICAO {
+	x := &ICAO{
+		airlines: make(map[string]string),
+		types:    make(map[string]string),
+	}
+
+	for fname, read := range map[string]func(io.Reader) error{
+		"ICAO_Airlines.txt": x.ReadAirlines,
+		"ICAO_Aircraft.txt": x.ReadAircraft,
+	} {
+		if f, err := os.Open(filepath.Join(dir, fname)); err != nil {
+			log.Println(err)
+		} else {
+			if err := read(f); err != nil {
+				log.Println(fname, err)
+			}
+			f.Close()
+		}
+	}
+
+	return x
+}
+
+// ReadAirlines reads lines like "DLH<TAB>Lufthansa - Germany<TAB>LUFTHANSA".
+// Both the name without the country and the callsign identify the airline.
+// The data has duplicates, such as two airlines named "British Airways"; the
+// first one wins.
+func (x *ICAO) ReadAirlines(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Split(s.Text(), "\t")
+		if len(f) < 3 || strings.HasPrefix(f[0], ";") {
+			continue
+		}
+		code := f[0]
+		name := f[1]
+		if i := strings.LastIndex(name, " - "); i > 0 {
+			name = name[:i]
+		}
+		for _, k := range []string{name, f[2]} {
+			k = strings.ToUpper(strings.TrimSpace(k))
+			if _, ok := x.airlines[k]; k == "" || k == "UNKNOWN" || ok {
+				continue
+			}
+			x.airlines[k] = code
+		}
+	}
+	return s.Err()
+}
+
+var parenPattern = regexp.MustCompile(`\([^)]*\)`)
+
+// ReadAircraft reads lines like "B738<TAB>ML2J<TAB>BOEING<TAB>737-800, BBJ2".
+// If several types have the same name, such as the two variants of the
+// "747-400", the first one wins.
+func (x *ICAO) ReadAircraft(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Split(s.Text(), "\t")
+		if len(f) < 4 || strings.HasPrefix(f[0], ";") {
+			continue
+		}
+		designator, manufacturer := f[0], f[2]
+		x.types[designator] = designator
+
+		var keys []string
+		for _, name := range strings.Split(parenPattern.ReplaceAllString(f[3], ""), ",") {
+			for _, name := range expandVariants(strings.TrimSpace(name)) {
+				keys = append(keys, name, manufacturer+name)
+				if i := strings.Index(manufacturer, " "); i > 0 {
+					// "MCDONNELL DOUGLAS" -> "MCDONNELL" as in "McDonnell MD-11".
+					keys = append(keys, manufacturer[:i]+name)
+				}
+			}
+		}
+		for _, k := range keys {
+			if k = typeKey(k); k == "" {
+				continue
+			}
+			if _, ok := x.types[k]; !ok {
+				x.types[k] = designator
+			}
+		}
+	}
+	return s.Err()
+}
+
+// expandVariants expands lists of variants such as "737-300/500" or
+// "A319/320/321" to "737-300", "737-500", and so on.
+func expandVariants(s string) []string {
+	variants := strings.Split(s, "/")
+	for i, v := range variants {
+		if i > 0 && len(v) < len(variants[0]) {
+			variants[i] = variants[0][:len(variants[0])-len(v)] + v
+		}
+	}
+	return variants
+}
+
+// typeKey reduces a free-form aircraft type name to upper-case letters and
+// digits, so that "B737-800", "b737 800" and "B737800" are all the same.
+func typeKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return -1
+		}
+	}, s)
+}
+
+// Airline returns the ICAO code of the airline named in s. Titles start with
+// the airline, so the earliest match wins, and then the longest one, for
+// instance "Lufthansa CityLine" over "Lufthansa".
+func (x *ICAO) Airline(s string) string {
+	s = " " + strings.ToUpper(strings.Join(strings.FieldsFunc(s, isSeparator), " ")) + " "
+
+	best, pos, code := "", len(s), ""
+	for name, c := range x.airlines {
+		i := strings.Index(s, " "+name+" ")
+		if i < 0 || i > pos || i == pos && len(name) <= len(best) {
+			continue
+		}
+		best, pos, code = name, i, c
+	}
+	return code
+}
+
+func isSeparator(r rune) bool {
+	switch r {
+	case ' ', '\t', ',', '(', ')', '/', '&':
+		return true
+	}
+	return false
+}
+
+var (
+	typeSuffixPattern = regexp.MustCompile(`^(.*[0-9])[A-Za-z]+$`) // "MD-11F"
+	typePrefixPattern = regexp.MustCompile(`^[A-Za-z]([0-9].*)$`)  // "B737-800"
+)
+
+// Types returns the ICAO type designators of the aircraft named in s.
+func (x *ICAO) Types(s string) []string {
+	words := strings.Fields(strings.NewReplacer(",", " ", "(", " ", ")", " ", "&", " ").Replace(s))
+
+	var types []string
+	seen := make(map[string]bool)
+	for i, w := range words {
+		if !strings.ContainsAny(w, "0123456789") {
+			continue
+		}
+
+		for _, v := range expandVariants(w) {
+			candidates := []string{v}
+			if i > 0 {
+				// As in "Boeing 737-800".
+				candidates = append(candidates, words[i-1]+v)
+			}
+			if m := typeSuffixPattern.FindStringSubmatch(v); m != nil {
+				candidates = append(candidates, m[1])
+			}
+			if m := typePrefixPattern.FindStringSubmatch(v); m != nil {
+				candidates = append(candidates, m[1])
+			}
+
+			for _, c := range candidates {
+				d := x.types[typeKey(c)]
+				if d == "" {
+					continue
+				}
+				if !seen[d] {
+					seen[d] = true
+					types = append(types, d)
+				}
+				break
+			}
+		}
+	}
+	return types
+}
@@ -16,10 +16,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 
@@ -33,78 +34,150 @@ func main() {
 		FSXRoot = d
 	}
 
+	dryRun := flag.Bool("dry-run", false, "Only print the files of the packages and where they would be installed.")
+	flag.DurationVar(&pageTTL, "cache-ttl", pageTTL, "How long to cache web pages. Packages are cached until removed with the cache command.")
+	jobs := flag.Int("j", 4, "Number of parallel downloads.")
+	policy := flag.String("conflicts", conflictOverwrite, "What to do if a file exists with different content: overwrite or skip. Overwritten files are backed up and restored by uninstall. Keeping both isn't possible, since FSX only loads files by the names that aircraft and scenery refer to. Conflicts are recorded in the manifest either way.")
+	qf := addQueryFlags(flag.CommandLine)
+	icaoDir := flag.String("icao-dir", "EuroScope/EDBB", "Directory with EuroScope's ICAO_Airlines.txt and ICAO_Aircraft.txt.")
+	flag.Usage = func() {
+		name := filepath.Base(os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-airline CODE] [-type CODE] [PATTERN]\n", name)
+		fmt.Fprintf(os.Stderr, "       %s search [-airline CODE] [-type CODE] [-json] [PATTERN]\n", name)
+		fmt.Fprintf(os.Stderr, "       %s gaps MISSING.csv|icao.json\n", name)
+		fmt.Fprintf(os.Stderr, "       %s list\n", name)
+		fmt.Fprintf(os.Stderr, "       %s uninstall DLID|PATTERN...\n", name)
+		fmt.Fprintf(os.Stderr, "       %s outdated|upgrade [PATTERN]\n", name)
+		fmt.Fprintf(os.Stderr, "       %s cache list|verify|prune|clear [FLAGS]\n", name)
+		fmt.Fprintf(os.Stderr, "\nMISSING.csv is written by model-matcher coverage -missing and lists the\n")
+		fmt.Fprintf(os.Stderr, "airlines and types without any installed model. icao.json lists all that\n")
+		fmt.Fprintf(os.Stderr, "were seen, even those covered by models from other sources.\n")
+		fmt.Fprintf(os.Stderr, "\nPATTERN is a regular expression for package titles. A PATTERN that equals\n")
+		fmt.Fprintf(os.Stderr, "a command, such as search, gaps, list or cache, runs the command instead;\n")
+		fmt.Fprintf(os.Stderr, "use (?:list) or search list to install or search for such titles.\n\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
-	args := flag.Args()
-	if len(args) != 1 {
-		fmt.Fprintf(os.Stderr, "Usage: %s PATTERN\n", filepath.Base(os.Args[0]))
+	if !conflictPolicies[*policy] {
+		flag.Usage()
 		os.Exit(1)
 	}
-	term := args[0]
-
-	nodes, doc, err := search(term)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	titles := make([]string, 0, len(nodes))
-	for title := range nodes {
-		titles = append(titles, title)
-	}
-	sort.Strings(titles)
-
-	for _, title := range titles {
-		fmt.Println(title)
-		for _, n := range nodes[title] {
-			b, dlID, err := downloadPackage(href(doc, n))
-			if err != nil {
-				fmt.Println("\t", err)
-				continue
-			}
+	args := flag.Args()
 
-			if err := installPackage(b, dlID); err != nil {
-				fmt.Println("\t", err)
-				continue
-			}
-			break
+	switch flag.Arg(0) {
+	case "search":
+		fs := flag.NewFlagSet("search", flag.ExitOnError)
+		asJSON := fs.Bool("json", false, "Print JSON.")
+		sqf := addQueryFlags(fs)
+		fs.Parse(args[1:])
+		if fs.NArg() > 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		// The query flags may also come before "search".
+		if *sqf.airline == "" {
+			sqf.airline = qf.airline
+		}
+		if *sqf.typ == "" {
+			sqf.typ = qf.typ
+		}
+		q, err := sqf.query(fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		ps, err := search(q, loadICAO(*icaoDir))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printPackages(os.Stdout, ps, *asJSON); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "gaps":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(1)
 		}
+		ps, err := fillGaps(os.Stdout, args[1], loadICAO(*icaoDir))
+		if err != nil {
+			log.Fatal(err)
+		}
+		installAll(ps, *jobs, *policy, *dryRun)
+		return
+	case "list":
+		ms, err := LoadManifests(manifestDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		listManifests(os.Stdout, ms)
+		return
+	case "uninstall":
+		if len(args) < 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		if err := uninstallPackages(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "cache":
+		if err := cacheCommand(os.Stdout, cacheDir, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "outdated", "upgrade":
+		if len(args) > 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		pattern := ""
+		if len(args) == 2 {
+			pattern = args[1]
+		}
+		if err := upgradePackages(os.Stdout, pattern, *policy, args[0] == "outdated" || *dryRun); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-}
 
-func search(pattern string) (map[string][]*html.Node, *goquery.Document, error) {
-	re, err := regexp.Compile("(?i)" + pattern)
-	if err != nil {
-		return nil, nil, err
+	if len(args) > 1 || len(args) == 0 && qf.empty() {
+		flag.Usage()
+		os.Exit(1)
 	}
-
-	req, err := http.NewRequest("GET", "http://www.world-of-ai.com/allpackages.php", nil)
+	q, err := qf.query(flag.Arg(0))
 	if err != nil {
-		return nil, nil, err
+		log.Fatal(err)
 	}
 
-	doc, err := parse(req)
+	ps, err := search(q, loadICAO(*icaoDir))
 	if err != nil {
-		return nil, nil, err
+		log.Fatal(err)
 	}
 
-	nodes := make(map[string][]*html.Node)
-	dlPageAnchors(nodes, re, doc.Find("#airlines ~ table").First())
-	dlPageAnchors(nodes, re, doc.Find("#cargo ~ table").First())
-
-	return nodes, doc, nil
+	installAll(ps, *jobs, *policy, *dryRun)
 }
 
-func dlPageAnchors(nodes map[string][]*html.Node, re *regexp.Regexp, table *goquery.Selection) {
-	table.Find("tr").Each(func(_ int, s *goquery.Selection) {
-		title := s.Find("td").Eq(1).Text()
-		if !re.MatchString(title) {
-			return
+// installAll downloads the packages in parallel, but installs one package at
+// a time, so that conflicts between packages are detected. With dryRun, the
+// files are only listed.
+func installAll(ps []*Package, jobs int, policy string, dryRun bool) {
+	for d := range downloadAll(ps, jobs) {
+		fmt.Println(d.title)
+		if d.err != nil {
+			fmt.Println("\t", d.err)
+			continue
 		}
 
-		s.Find("td").Eq(5).Each(func(_ int, s *goquery.Selection) {
-			x := s.Find("a[href*='library.avsim.net/search.php']").Last().Get(0)
-			nodes[title] = append(nodes[title], x)
-		})
-	})
+		var err error
+		if dryRun {
+			err = listPackage(os.Stdout, d.fname, d.dlID)
+		} else {
+			err = installPackage(d.fname, d.dlID, d.title, policy)
+		}
+		if err != nil {
+			fmt.Println("\t", err)
+		}
+	}
 }
 
 func href(doc *goquery.Document, n *html.Node) string {
@@ -139,69 +212,104 @@ func parse(req *http.Request) (*goquery.Document, error) {
 	return doc, nil
 }
 
+// fetch returns the response body for req, from the cache if possible. See
+// pageTTL for how long responses are cached.
 func fetch(req *http.Request) (io.ReadCloser, error) {
-	fname := filepath.Join(".cache", req.Method, req.URL.String())
-	fname = strings.Map(func(r rune) rune {
-		switch r {
-		case ':', '?':
-			return '_'
-		default:
-			return r
-		}
-	}, fname)
+	fname := cacheFile(req)
 	f, err := os.Open(fname)
 	if err == nil {
-		return f, nil
+		fi, err := f.Stat()
+		if err == nil && !expired(req.URL.String(), fi.ModTime()) {
+			return f, nil
+		}
+		f.Close()
+		log.Println("cache expired ", req.URL.String())
+	} else {
+		log.Println("cache miss ", req.URL.String())
 	}
-	log.Println("cache miss ", req.URL.String())
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.New(res.Status)
+	}
 
-	return TeeFileReader(res.Body, fname)
+	return TeeFileReader(res.Body, fname, res.ContentLength)
 }
 
-// downloadPackage returns the bytes for the outermost zip archive for a
-// package. dlPageURL is the URL that is linked on
-// http://www.world-of-ai.com/packages.php.
-func downloadPackage(dlPageURL string) (b []byte, dlID string, err error) {
+// downloadPackage downloads the outermost zip archive for a package and
+// returns the name of the file in the cache. dlPageURL is the URL that is
+// linked on http://www.world-of-ai.com/packages.php.
+func downloadPackage(dlPageURL, title string) (fname, dlID string, err error) {
+	dlID, err = packageDLID(dlPageURL, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	fname, err = downloadDLID(dlID, title)
+	if err != nil {
+		return "", "", err
+	}
+	return fname, dlID, nil
+}
+
+// packageDLID returns the AVSIM download ID of the package linked on the
+// download page dlPageURL. If refresh is set, the download page is fetched
+// again even if it is cached.
+func packageDLID(dlPageURL string, refresh bool) (string, error) {
 	req, err := http.NewRequest("GET", dlPageURL, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if refresh {
+		os.Remove(cacheFile(req))
+	}
 
 	doc, err := parse(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("Fetch download page: %v", err)
+		return "", fmt.Errorf("Fetch download page: %v", err)
 	}
 
 	nodes := doc.Find("a[href^='download.php?DLID=']").First().Nodes
 	if len(nodes) < 1 {
-		return nil, "", fmt.Errorf("Interstitial link not found: %v", dlPageURL)
+		return "", fmt.Errorf("Interstitial link not found: %v", dlPageURL)
 	}
 
 	interstitialURL, err := url.Parse(href(doc, nodes[0]))
 	if err != nil {
-		return nil, "", fmt.Errorf("Cannot parse interstitial URL: %v", err)
+		return "", fmt.Errorf("Cannot parse interstitial URL: %v", err)
 	}
 
-	dlID = interstitialURL.Query().Get("DLID")
+	dlID := interstitialURL.Query().Get("DLID")
 	if dlID == "" {
-		return nil, "", fmt.Errorf("Empty DLID in interstitial URL: %v", interstitialURL)
+		return "", fmt.Errorf("Empty DLID in interstitial URL: %v", interstitialURL)
 	}
+	return dlID, nil
+}
 
+// downloadDLID downloads the outermost zip archive with the AVSIM download ID
+// dlID into the cache, unless it is there already, and returns the name of
+// the file. Progress is reported with label.
+func downloadDLID(dlID, label string) (string, error) {
 	dlQuery := make(url.Values)
 	dlQuery.Set("Location", "AVSIM")
 	dlQuery.Set("Proto", "ftp") // wat?
 	dlQuery.Set("DLID", dlID)
 
-	req, err = http.NewRequest("GET", "https://library.avsim.net/sendfile.php?"+dlQuery.Encode(), nil)
+	req, err := http.NewRequest("GET", "https://library.avsim.net/sendfile.php?"+dlQuery.Encode(), nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	fname := cacheFile(req)
+	defer lockFile(fname)()
+	if _, err := os.Stat(fname); err == nil {
+		return fname, nil
+	}
+
 	cookie := make(url.Values)
 	loginToken := os.Getenv("AVSIM_LOGIN")
 	if loginToken == "" {
@@ -210,41 +318,81 @@ func downloadPackage(dlPageURL string) (b []byte, dlID string, err error) {
 	cookie.Set("LibraryLogin", loginToken)
 	req.Header.Set("Cookie", cookie.Encode())
 
-	r, err := fetch(req)
-	if err != nil {
-		return nil, "", fmt.Errorf("Cannot download %s: %v\n", dlQuery.Encode(), err)
+	if err := downloadFile(req, fname, label); err != nil {
+		return "", fmt.Errorf("Cannot download %s: %v\n", dlQuery.Encode(), err)
 	}
-	defer r.Close()
+	return fname, nil
+}
 
-	b, err = ioutil.ReadAll(r)
+// uninstallPackages uninstalls the packages whose DLID equals one of the
+// arguments or whose title matches one of them.
+func uninstallPackages(args []string) error {
+	ms, err := LoadManifests(manifestDir)
 	if err != nil {
-		return nil, "", fmt.Errorf("Cannot read %s: %v\n", dlID, err)
+		return err
+	}
+
+	var res []*regexp.Regexp
+	for _, arg := range args {
+		re, err := regexp.Compile("(?i)" + arg)
+		if err != nil {
+			return err
+		}
+		res = append(res, re)
+	}
+
+	remaining := ms
+	for _, m := range ms {
+		match := false
+		for i, re := range res {
+			if m.DLID == args[i] || re.MatchString(m.Title) {
+				match = true
+			}
+		}
+		if !match {
+			continue
+		}
+
+		fmt.Println(m.Title, m.DLID)
+		if err := uninstall(FSXRoot, manifestDir, m, remaining); err != nil {
+			return err
+		}
+
+		var rest []*Manifest
+		for _, o := range remaining {
+			if o != m {
+				rest = append(rest, o)
+			}
+		}
+		remaining = rest
 	}
 
-	return b, dlID, nil
+	return nil
 }
 
-// installPackage installs a single World of AI Package. raw are the bytes of
-// the outermost zip archive, as returned by downloadPackage.
-func installPackage(raw []byte, dlID string) error {
-	z, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+// openPackage unwraps and decrypts a World of AI package. fname is the
+// outermost zip archive, as returned by downloadPackage. digest is the hex
+// encoded SHA1 of the decrypted archive.
+func openPackage(fname, dlID string) (z *zip.Reader, digest string, err error) {
+	f, err := zip.OpenReader(fname)
 	if err != nil {
-		return fmt.Errorf("Cannot read zip %s: %v\n", dlID, err)
+		return nil, "", fmt.Errorf("Cannot read zip %s: %v\n", dlID, err)
 	}
+	defer f.Close()
 
-	raw, err = unzip(z, ".woai.zip")
+	raw, err := unzip(&f.Reader, ".woai.zip")
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	z, err = zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
 	if err != nil {
-		return fmt.Errorf("Cannot read %s.woai.zip: %v\n", dlID, err)
+		return nil, "", fmt.Errorf("Cannot read %s.woai.zip: %v\n", dlID, err)
 	}
 
 	raw, err = unzip(z, ".woai.enc")
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	if err := decrypt(raw); err != nil {
@@ -253,86 +401,280 @@ func installPackage(raw []byte, dlID string) error {
 
 	z, err = zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
 	if err != nil {
-		return fmt.Errorf("Cannot read %s.woai.enc: %v\n", dlID, err)
+		return nil, "", fmt.Errorf("Cannot read %s.woai.enc: %v\n", dlID, err)
 	}
 
 	hash := sha1.New()
 	hash.Write(raw)
-	digest := hex.EncodeToString(hash.Sum(nil))
-	created := make(map[string]bool) // set of already created directories, so we can skip MkdirAll.
+	return z, hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	for _, zf := range z.File {
-		name := filepath.Clean(strings.Replace(zf.Name, `\`, "/", -1))
-		iname := strings.ToLower(name)
-		dest := name
+// destination maps the name of a file in a decrypted package to its path
+// relative to FSXRoot. If the file is not installed, dest is empty; ignored
+// tells whether that is expected, for instance for the readme files.
+func destination(name, digest string) (dest string, ignored bool) {
+	iname := strings.ToLower(name)
+	dest = name
+
+	switch {
+	case isTrafficFile(name):
+		return filepath.Join(trafficDir, path.Base(name)), false
+
+	case strings.HasPrefix(iname, "aircraft/"):
+		// Change dest such that we can write to FSXRoot/dest.
+		//
+		// dest is something like "aircraft/WoA_AIA_B733v2_Winglet/Aircraft.cfg".
+		// Replace the first segment with "SimObjects/Airplanes" and prepend
+		// part of the ZIP hash to the second segment (they are not unique
+		// across packages).
+		dest = strings.TrimPrefix(dest, "aircraft/")
+		dest = digest[:8] + "-" + dest
+		dest = filepath.Join("SimObjects/Airplanes", dest)
+
+	case strings.HasPrefix(iname, "texture/"),
+		strings.HasPrefix(iname, "scenery/"),
+		strings.HasPrefix(iname, "effects/"):
+		// Not sure if there are collisions here, but it probably doesn't
+		// matter much if there are.
+
+	case strings.HasSuffix(iname, ".txt"),
+		strings.HasPrefix(iname, "addon scenery/"),
+		iname == "avsim.diz",
+		iname == "woai.cfg",
+		iname == "version.ini":
+		return "", true
+	default:
+		return "", false
+	}
+
+	if strings.HasSuffix(dest, "Aircraft.cfg") {
+		dest = strings.TrimSuffix(dest, "Aircraft.cfg") + "aircraft.cfg"
+	}
+	return dest, false
+}
 
-		switch {
-		case strings.HasPrefix(iname, "aircraft/"):
-			// Change dest such that we can write to FSXRoot/dest.
-			//
-			// dest is something like "aircraft/WoA_AIA_B733v2_Winglet/Aircraft.cfg".
-			// Replace the first segment with "SimObjects/Airplanes" and prepend
-			// part of the ZIP hash to the second segment (they are not unique
-			// across packages).
-			dest = strings.TrimPrefix(dest, "aircraft/")
-			dest = digest[:8] + "-" + dest
-			dest = filepath.Join("SimObjects/Airplanes", dest)
-
-		case strings.HasPrefix(iname, "texture/"),
-			strings.HasPrefix(iname, "scenery/"),
-			strings.HasPrefix(iname, "effects/"):
-			// Not sure if there are collisions here, but it probably doesn't
-			// matter much if there are.
-
-		case strings.HasSuffix(iname, ".txt"),
-			strings.HasPrefix(iname, "addon scenery/"),
-			iname == "avsim.diz",
-			iname == "woai.cfg",
-			iname == "version.ini":
-			// ignore
+// entryName returns the cleaned, slash separated name of a file in a package.
+func entryName(zf *zip.File) string {
+	return filepath.Clean(strings.Replace(zf.Name, `\`, "/", -1))
+}
+
+// listPackage prints the files of a package and where installPackage would
+// write them, without writing anything.
+func listPackage(w io.Writer, fname, dlID string) error {
+	z, digest, err := openPackage(fname, dlID)
+	if err != nil {
+		return err
+	}
+
+	havePlans, haveTraffic := false, false
+	for _, zf := range z.File {
+		name := entryName(zf)
+		haveTraffic = haveTraffic || isTrafficFile(name)
+		if kind, _ := planKind(name); kind != "" {
+			fmt.Fprintf(w, "\t%s (flight plans)\n", name)
+			havePlans = true
 			continue
+		}
+		switch dest, ignored := destination(name, digest); {
+		case ignored:
+			fmt.Fprintf(w, "\t%s (ignored)\n", name)
+		case dest == "":
+			fmt.Fprintf(w, "\t%s (skipped)\n", name)
 		default:
-			log.Println("skipping ", name)
-			continue
+			fname := filepath.Join(FSXRoot, dest)
+			if conflicts(zf, fname) {
+				fmt.Fprintf(w, "\t%s -> %s (conflict)\n", name, fname)
+			} else {
+				fmt.Fprintf(w, "\t%s -> %s\n", name, fname)
+			}
+		}
+	}
+	if havePlans {
+		fmt.Fprintf(w, "\tmerged flight plans -> %s\n", filepath.Join(FSXRoot, planDir(digest)))
+		if !haveTraffic {
+			fmt.Fprintln(w, "\tno traffic file; installation fails")
+		}
+	}
+	return nil
+}
+
+// planDir returns the directory of the merged flight plans of a package,
+// relative to FSXRoot.
+func planDir(digest string) string {
+	return filepath.Join(planRoot, digest[:8])
+}
+
+// installPackage installs a single World of AI Package. fname is the
+// outermost zip archive, as returned by downloadPackage. The written files
+// are recorded in the package's manifest, even if an error occurs halfway.
+// policy determines what happens to files of other packages that would be
+// overwritten; see conflictPolicies.
+func installPackage(fname, dlID, title, policy string) (err error) {
+	z, digest, err := openPackage(fname, dlID)
+	if err != nil {
+		return err
+	}
+
+	m := &Manifest{
+		DLID:      dlID,
+		Title:     title,
+		Digest:    digest,
+		Version:   packageVersion(z),
+		Installed: time.Now(),
+	}
+	defer func() {
+		if len(m.Files) == 0 && len(m.Conflicts) == 0 {
+			return
+		}
+		if e := m.Save(manifestDir); e != nil && err == nil {
+			err = e
 		}
+	}()
+
+	owners, err := fileOwners(manifestDir)
+	if err != nil {
+		return err
+	}
+	in := &installer{
+		m:       m,
+		created: make(map[string]bool),
+		owners:  owners,
+		policy:  policy,
+		dir:     manifestDir,
+	}
 
-		if strings.HasSuffix(dest, "Aircraft.cfg") {
-			dest = strings.TrimSuffix(dest, "Aircraft.cfg") + "aircraft.cfg"
+	var plans FlightPlans
+	haveTraffic := false
+	for _, zf := range z.File {
+		name := entryName(zf)
+		kind, set := planKind(name)
+		dest, ignored := destination(name, digest)
+		if kind == "" && dest == "" {
+			if !ignored {
+				log.Println("skipping ", name)
+			}
+			continue
 		}
-		fname := filepath.Join(FSXRoot, dest)
 
 		r, err := zf.Open()
 		if err != nil {
 			return fmt.Errorf("Cannot open %s.woai.enc/%s: %v\n", dlID, name, err)
 		}
 
-		dir := filepath.Dir(fname)
-		if !created[dir] {
-			if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
-				return err
+		if kind != "" {
+			err = plans.Add(kind, set, r)
+			r.Close()
+			if err != nil {
+				return fmt.Errorf("Cannot read %s: %v\n", name, err)
 			}
-			created[dir] = true
+			continue
 		}
 
-		f, err := os.Create(fname)
-		if err != nil {
-			r.Close()
-			return fmt.Errorf("Cannot create %s: %v\n", fname, err)
-		}
-		_, err = io.Copy(f, r)
+		err = in.extract(dest, r)
 		r.Close()
-		f.Close()
 		if err != nil {
 			return fmt.Errorf("Cannot extract %s: %v\n", name, err)
 		}
-		if strings.HasSuffix(strings.ToLower(fname), "aircraft.cfg") {
-			fmt.Println("\t", fname)
+
+		switch {
+		case isTrafficFile(name):
+			haveTraffic = true
+			fallthrough
+		case strings.HasSuffix(strings.ToLower(dest), "aircraft.cfg"):
+			fmt.Println("\t", filepath.Join(FSXRoot, dest))
+		}
+	}
+
+	if plans.Empty() {
+		return nil
+	}
+
+	dir := planDir(digest)
+	merged := plans.Merge()
+	for _, kind := range []string{aircraftPlans, airportsPlans, flightplansPlans} {
+		r := strings.NewReader(strings.Join(merged[kind], "\r\n") + "\r\n")
+		if err := in.extract(filepath.Join(dir, planFileName(kind, digest)), r); err != nil {
+			return fmt.Errorf("Cannot write flight plans: %v\n", err)
 		}
 	}
+	if !haveTraffic {
+		// We can't compile traffic files ourselves, and without one
+		// nothing flies.
+		return fmt.Errorf("Package has flight plans but no traffic file; compile %s with TTools into %s\n",
+			filepath.Join(FSXRoot, dir), filepath.Join(FSXRoot, trafficDir))
+	}
 
 	return nil
 }
 
+// installer writes the files of a package and records them in its manifest.
+type installer struct {
+	m       *Manifest
+	created map[string]bool // set of already created directories, so we can skip MkdirAll.
+	owners  map[string]*Manifest
+	policy  string // what to do about conflicts; see conflictPolicies
+	dir     string // manifest directory, which holds the backups of overwritten files
+}
+
+// extract writes the content of r to FSXRoot/dest and records the file in the
+// manifest. If the file exists with different content, the conflict policy
+// applies.
+func (in *installer) extract(dest string, r io.Reader) error {
+	fname := filepath.Join(FSXRoot, dest)
+
+	sum, err := fileSHA1(fname)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+
+		h := sha1.New()
+		h.Write(b)
+		if hex.EncodeToString(h.Sum(nil)) == sum {
+			// Same file; the packages share it now.
+			in.m.Files = append(in.m.Files, ManifestFile{Path: filepath.ToSlash(dest), SHA1: sum})
+			return nil
+		}
+
+		owner := in.owners[filepath.ToSlash(dest)]
+		if owner == nil || owner.DLID != in.m.DLID {
+			if dest, err = in.conflict(dest, owner); dest == "" {
+				return err
+			}
+			fname = filepath.Join(FSXRoot, dest)
+		}
+	}
+
+	dir := filepath.Dir(fname)
+	if !in.created[dir] {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		in.created[dir] = true
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	h := sha1.New()
+	_, err = io.Copy(io.MultiWriter(f, h), r)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	in.m.Files = append(in.m.Files, ManifestFile{
+		Path: filepath.ToSlash(dest),
+		SHA1: hex.EncodeToString(h.Sum(nil)),
+	})
+	return err
+}
+
 func unzip(z *zip.Reader, fnameSuffix string) ([]byte, error) {
 	for _, f := range z.File {
 		if !strings.HasSuffix(f.Name, fnameSuffix) {
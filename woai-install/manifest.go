@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestDir is where the manifests of installed packages are kept, one
+// file per DLID.
+const manifestDir = ".manifests"
+
+// A Manifest records what installPackage wrote for a package, so that it can
+// be uninstalled again.
+type Manifest struct {
+	DLID      string
+	Title     string
+	Digest    string // SHA1 of the decrypted package
+	Version   string `json:",omitempty"` // from version.ini
+	Installed time.Time
+	Files     []ManifestFile
+	Conflicts []Conflict `json:",omitempty"`
+}
+
+type ManifestFile struct {
+	Path string // relative to FSXRoot, with forward slashes
+	SHA1 string
+}
+
+func manifestFile(dir, dlID string) string {
+	return filepath.Join(dir, dlID+".json")
+}
+
+// Save writes the manifest to dir.
+func (m *Manifest) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fname := manifestFile(dir, m.DLID)
+	tmp := fname + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fname)
+}
+
+// LoadManifests reads all manifests in dir, ordered by DLID. A missing
+// directory means that nothing has been installed yet.
+func LoadManifests(dir string) ([]*Manifest, error) {
+	fnames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []*Manifest
+	for _, fname := range fnames {
+		b, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		m := &Manifest{}
+		if err := json.Unmarshal(b, m); err != nil {
+			return nil, fmt.Errorf("Cannot parse %s: %v", fname, err)
+		}
+		ms = append(ms, m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].DLID < ms[j].DLID })
+	return ms, nil
+}
+
+// listManifests implements the list command.
+func listManifests(w io.Writer, ms []*Manifest) {
+	for _, m := range ms {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d files\t%s\n", m.DLID, m.Installed.Format("2006-01-02"), m.Version, len(m.Files), m.Title)
+	}
+}
+
+// uninstall removes the files of m from root, then the package directories
+// that became empty (see packageDir), and finally the manifest in dir. Files
+// that m overwrote are restored from their backups instead. Files that have
+// been modified since installation, or that are also part of another
+// installed package, are left alone.
+func uninstall(root, dir string, m *Manifest, others []*Manifest) error {
+	shared := make(map[string]bool)               // paths of files of other packages
+	identical := make(map[ManifestFile]*Manifest) // with the same content
+	for _, o := range others {
+		if o.DLID == m.DLID {
+			continue
+		}
+		for _, f := range o.Files {
+			shared[f.Path] = true
+			identical[f] = o
+		}
+	}
+
+	backedUp := make(map[string]bool)
+	for _, c := range m.Conflicts {
+		if c.Backup {
+			backedUp[c.Path] = true
+		}
+	}
+
+	var kept []ManifestFile
+	dirs := make(map[string]string) // directory -> package directory
+	for _, f := range m.Files {
+		fname := filepath.Join(root, filepath.FromSlash(f.Path))
+
+		// An overwritten file of another package is restored, unless a
+		// package installed later has the same file. That package gets
+		// the backup then.
+		backup := ""
+		if backedUp[f.Path] {
+			backup = backupFile(dir, m.DLID, f.Path)
+		}
+		if o := identical[f]; o != nil && backup != "" {
+			if err := handOverBackup(dir, m, o, f.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		if shared[f.Path] && backup == "" {
+			continue
+		}
+
+		sum, err := fileSHA1(fname)
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			return err
+		case sum != f.SHA1:
+			fmt.Fprintln(os.Stderr, "\tmodified, keeping", fname)
+			kept = append(kept, f)
+			continue
+		}
+
+		if backup != "" {
+			if err := copyFile(fname, backup); err != nil {
+				return err
+			}
+			os.Remove(backup)
+			removeEmptyDirs(filepath.Join(dir, "backups"), filepath.Dir(backup))
+			continue
+		}
+
+		if err := os.Remove(fname); err != nil {
+			return err
+		}
+		if top := packageDir(f.Path); top != "" {
+			dirs[filepath.Dir(fname)] = filepath.Join(root, filepath.FromSlash(top))
+		}
+	}
+
+	for d, top := range dirs {
+		removeEmptyDirs(top, d)
+	}
+
+	if len(kept) > 0 {
+		m.Files = kept
+		return m.Save(dir)
+	}
+	return os.Remove(manifestFile(dir, m.DLID))
+}
+
+// handOverBackup moves the backup of the file rel from the package m to the
+// package o, which has the same file, so that uninstalling o restores it.
+func handOverBackup(dir string, m, o *Manifest, rel string) error {
+	from, to := backupFile(dir, m.DLID, rel), backupFile(dir, o.DLID, rel)
+	if err := copyFile(to, from); err != nil {
+		return err
+	}
+	os.Remove(from)
+	removeEmptyDirs(filepath.Join(dir, "backups"), filepath.Dir(from))
+
+	for _, c := range m.Conflicts {
+		if c.Path == rel {
+			o.Conflicts = append(o.Conflicts, c)
+		}
+	}
+	return o.Save(dir)
+}
+
+// packageContainers are the directories, relative to FSXRoot, whose
+// subdirectories each belong to a single package.
+var packageContainers = []string{
+	"SimObjects/Airplanes",
+	planRoot,
+}
+
+// packageDir returns the directory that the installed file rel belongs to
+// as a whole, such as "SimObjects/Airplanes/0123abcd-WoA_DLH_A320". It is
+// empty for files in directories that are shared with FSX and other add-ons,
+// such as Effects/Texture.
+func packageDir(rel string) string {
+	for _, c := range packageContainers {
+		if !strings.HasPrefix(rel, c+"/") {
+			continue
+		}
+		if i := strings.Index(rel[len(c)+1:], "/"); i > 0 {
+			return rel[:len(c)+1+i]
+		}
+	}
+	return ""
+}
+
+// removeEmptyDirs removes d and its parents up to and including top as long
+// as they are empty.
+func removeEmptyDirs(top, d string) {
+	top = filepath.Clean(top)
+	for d = filepath.Clean(d); len(d) >= len(top); d = filepath.Dir(d) {
+		if os.Remove(d) != nil {
+			// Not empty, or gone already.
+			return
+		}
+		if d == top {
+			return
+		}
+	}
+}
+
+func fileSHA1(fname string) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
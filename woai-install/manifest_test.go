@@ -0,0 +1,193 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUninstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "woai-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, manifestDir)
+
+	testCases := []struct {
+		path     string
+		shared   bool // also part of another package
+		modified bool // changed after installation
+		wantKept bool
+	}{
+		{"SimObjects/Airplanes/0123abcd-WoA_DLH_A320/aircraft.cfg", false, false, false},
+		{"SimObjects/Airplanes/0123abcd-WoA_DLH_A320/texture.DLH/fuselage.bmp", false, false, false},
+		{"SimObjects/Airplanes/0123abcd-WoA_DLH_A321/aircraft.cfg", false, true, true},
+		{"Effects/Texture/woai_light.bmp", false, false, false},
+		{"Effects/Texture/woai_shared.bmp", true, false, true},
+		{"World of AI/Flightplans/0123abcd/Aircraft_0123abcd.txt", false, false, false},
+	}
+
+	m := &Manifest{DLID: "12345", Title: "WoAI Lufthansa"}
+	other := &Manifest{DLID: "67890", Title: "WoAI Condor"}
+	for _, tc := range testCases {
+		fname := filepath.Join(root, filepath.FromSlash(tc.path))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(tc.path), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := fileSHA1(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Files = append(m.Files, ManifestFile{tc.path, sum})
+		if tc.shared {
+			other.Files = append(other.Files, ManifestFile{tc.path, sum})
+		}
+		if tc.modified {
+			if err := ioutil.WriteFile(fname, []byte("repainted"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := uninstall(root, dir, m, []*Manifest{m, other}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(tc.path)))
+		if kept := err == nil; kept != tc.wantKept {
+			t.Errorf("%s: kept == %v, want %v", tc.path, kept, tc.wantKept)
+		}
+	}
+
+	// Package directories are removed once empty, shared ones never.
+	for d, want := range map[string]bool{
+		"SimObjects/Airplanes/0123abcd-WoA_DLH_A320": false,
+		"SimObjects/Airplanes":                       true,
+		"Effects/Texture":                            true,
+		"World of AI/Flightplans/0123abcd":           false,
+		"World of AI/Flightplans":                    true,
+	} {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(d)))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s: exists == %v, want %v", d, exists, want)
+		}
+	}
+
+	// The manifest now only lists the modified file.
+	ms, err := LoadManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || len(ms[0].Files) != 1 || ms[0].Files[0].Path != testCases[2].path {
+		t.Errorf("manifests after uninstall: %+v", ms)
+	}
+}
+
+func TestUninstallRestoresBackups(t *testing.T) {
+	root, err := ioutil.TempDir("", "woai-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, manifestDir)
+
+	defer func(root string) { FSXRoot = root }(FSXRoot)
+	FSXRoot = root
+
+	const (
+		stock  = "Effects/Texture/fx_stock.bmp"   // no known owner
+		owned  = "Effects/Texture/woai_owned.bmp" // part of another package
+		shared = "Effects/Texture/woai_shared.bmp"
+	)
+	other := &Manifest{DLID: "67890", Title: "WoAI Condor"}
+	for _, path := range []string{stock, owned, shared} {
+		fname := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := fileSHA1(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == owned {
+			other.Files = append(other.Files, ManifestFile{path, sum})
+		}
+	}
+
+	in := &installer{
+		m:       &Manifest{DLID: "12345", Title: "WoAI Lufthansa"},
+		created: make(map[string]bool),
+		owners:  map[string]*Manifest{owned: other},
+		policy:  conflictOverwrite,
+		dir:     dir,
+	}
+	for _, path := range []string{stock, owned, shared} {
+		if err := in.extract(path, strings.NewReader("new")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A package installed later that has the same shared file needs it.
+	later := &Manifest{DLID: "24680", Title: "WoAI Eurowings"}
+	for _, f := range in.m.Files {
+		if f.Path == shared {
+			later.Files = append(later.Files, f)
+		}
+	}
+
+	for _, m := range []*Manifest{in.m, other, later} {
+		if err := m.Save(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := uninstall(root, dir, in.m, []*Manifest{other, later}); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		stock:  "old",
+		owned:  "old",
+		shared: "new",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s == %q, want %q", path, b, want)
+		}
+	}
+
+	// The backup of the shared file now belongs to the later package.
+	for path, want := range map[string]string{stock: "", owned: "", shared: later.DLID} {
+		for _, dlID := range []string{in.m.DLID, later.DLID} {
+			_, err := os.Stat(backupFile(dir, dlID, path))
+			if exists := err == nil; exists != (dlID == want) {
+				t.Errorf("backup of %s for %s: exists == %v", path, dlID, exists)
+			}
+		}
+	}
+	ms, err := LoadManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ms {
+		if m.DLID == later.DLID && (len(m.Conflicts) != 1 || !m.Conflicts[0].Backup) {
+			t.Errorf("conflicts of %s == %+v", m.DLID, m.Conflicts)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const allPackagesURL = "http://www.world-of-ai.com/allpackages.php"
+
+// A Package is a row of the package tables on allpackages.php.
+type Package struct {
+	Title   string
+	Cargo   bool     `json:",omitempty"`
+	Airline string   `json:",omitempty"` // ICAO code
+	Types   []string `json:",omitempty"` // ICAO type designators
+	Version string   `json:",omitempty"`
+	Links   []string // download pages, see downloadPackage
+}
+
+// Query selects packages. Empty fields match all packages.
+type Query struct {
+	Pattern *regexp.Regexp // matched against the title
+	Airline string
+	Type    string
+}
+
+func (q Query) Match(p *Package) bool {
+	if q.Pattern != nil && !q.Pattern.MatchString(p.Title) {
+		return false
+	}
+	if q.Airline != "" && !strings.EqualFold(q.Airline, p.Airline) {
+		return false
+	}
+	if q.Type == "" {
+		return true
+	}
+	for _, t := range p.Types {
+		if strings.EqualFold(q.Type, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryFlags are the command line flags that make up a Query.
+type queryFlags struct {
+	airline *string
+	typ     *string
+}
+
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	return &queryFlags{
+		airline: fs.String("airline", "", "Select packages of the airline with this ICAO code, such as DLH."),
+		typ:     fs.String("type", "", "Select packages with this ICAO aircraft type, such as B738."),
+	}
+}
+
+// query returns the Query for the flags and a regular expression for the
+// titles, which may be empty.
+func (f *queryFlags) query(pattern string) (Query, error) {
+	q := Query{
+		Airline: *f.airline,
+		Type:    *f.typ,
+	}
+	if pattern != "" {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return q, err
+		}
+		q.Pattern = re
+	}
+	return q, nil
+}
+
+func (f *queryFlags) empty() bool {
+	return *f.airline == "" && *f.typ == ""
+}
+
+// search returns the packages listed on allpackages.php that match q, sorted
+// by title.
+func search(q Query, icao *ICAO) ([]*Package, error) {
+	req, err := http.NewRequest("GET", allPackagesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	byTitle := make(map[string]*Package)
+	parsePackages(byTitle, doc, doc.Find("#airlines ~ table").First(), false, icao)
+	parsePackages(byTitle, doc, doc.Find("#cargo ~ table").First(), true, icao)
+
+	var ps []*Package
+	for _, p := range byTitle {
+		if q.Match(p) {
+			ps = append(ps, p)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Title < ps[j].Title })
+	return ps, nil
+}
+
+// packageColumns locates the columns of a package table by the header row.
+// The title and the download links are in the second and sixth column if
+// there are no headers; the others are -1 if not found.
+func packageColumns(table *goquery.Selection) (title, types, version, links int) {
+	title, types, version, links = 1, -1, -1, 5
+
+	table.Find("tr").First().Find("th").Each(func(i int, s *goquery.Selection) {
+		switch h := strings.ToLower(s.Text()); {
+		case strings.Contains(h, "version"):
+			version = i
+		case strings.Contains(h, "aircraft"), strings.Contains(h, "type"):
+			types = i
+		case strings.Contains(h, "download"):
+			links = i
+		case strings.Contains(h, "package"), strings.Contains(h, "title"):
+			title = i
+		}
+	})
+	return
+}
+
+// parsePackages adds the rows of a package table to byTitle. Rows with the
+// same title, which are mirrors, are merged.
+func parsePackages(byTitle map[string]*Package, doc *goquery.Document, table *goquery.Selection, cargo bool, icao *ICAO) {
+	titleCol, typesCol, versionCol, linksCol := packageColumns(table)
+
+	table.Find("tr").Each(func(_ int, s *goquery.Selection) {
+		tds := s.Find("td")
+		title := strings.TrimSpace(tds.Eq(titleCol).Text())
+		if title == "" {
+			return
+		}
+
+		p := byTitle[title]
+		if p == nil {
+			p = &Package{
+				Title:   title,
+				Cargo:   cargo,
+				Airline: icao.Airline(title),
+				Types:   icao.Types(title),
+			}
+			if typesCol >= 0 {
+				for _, t := range icao.Types(tds.Eq(typesCol).Text()) {
+					p.addType(t)
+				}
+			}
+			if versionCol >= 0 {
+				p.Version = strings.TrimSpace(tds.Eq(versionCol).Text())
+			}
+			byTitle[title] = p
+		}
+
+		a := tds.Eq(linksCol).Find("a[href*='library.avsim.net/search.php']").Last()
+		if href, ok := a.Attr("href"); ok {
+			if u, err := url.Parse(href); err == nil {
+				p.Links = append(p.Links, doc.Url.ResolveReference(u).String())
+			}
+		}
+	})
+}
+
+func (p *Package) addType(t string) {
+	for _, x := range p.Types {
+		if x == t {
+			return
+		}
+	}
+	p.Types = append(p.Types, t)
+}
+
+// printPackages prints the packages as a table, or as JSON if asJSON is set.
+func printPackages(w io.Writer, ps []*Package, asJSON bool) error {
+	if asJSON {
+		if ps == nil {
+			ps = []*Package{}
+		}
+		b, err := json.MarshalIndent(ps, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+
+	for _, p := range ps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Airline, strings.Join(p.Types, ","), p.Version, p.Title)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	testAirlines = "BAW\tBritish Airways - United Kingdom\tSPEEDBIRD\n" +
+		"BOE\tBoeing - United States\tBOEING\n" +
+		"CFG\tCondor Flugdienst - Germany\tCONDOR\n" +
+		"CIB\tCondor - Germany\tCONDOR BERLIN\n" +
+		"CLH\tLufthansa CityLine - Germany\tHANSALINE\n" +
+		"DLH\tLufthansa - Germany\tLUFTHANSA\n" +
+		"XMS\tBritish Airways - United Kingdom\tSANTA\n"
+	testAircraft = "B733\tL2J\tBOEING\t737-300\n" +
+		"B735\tL2J\tBOEING\t737-500\n" +
+		"B738\tL2J\tBOEING\t737-800, BBJ2\n" +
+		"B744\tL4J\tBOEING\t747-400 (international, winglets)\n" +
+		"B74D\tL4J\tBOEING\t747-400 (domestic, no winglets)\n" +
+		"B753\tL2J\tBOEING\t757-300\n" +
+		"CRJ9\tL2J\tBOMBARDIER\tCRJ-705/900\n" +
+		"MD11\tL3J\tMCDONNELL DOUGLAS\tMD-11\n"
+)
+
+func testICAO(t *testing.T) *ICAO {
+	x := &ICAO{
+		airlines: make(map[string]string),
+		types:    make(map[string]string),
+	}
+	if err := x.ReadAirlines(strings.NewReader(testAirlines)); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.ReadAircraft(strings.NewReader(testAircraft)); err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func TestICAO(t *testing.T) {
+	x := testICAO(t)
+
+	testCases := []struct {
+		title   string
+		airline string
+		types   []string
+	}{
+		{"Lufthansa Boeing 737-300/500", "DLH", []string{"B733", "B735"}},
+		{"Lufthansa CityLine CRJ-900", "CLH", []string{"CRJ9"}},
+		{"Condor Boeing 757-300", "CFG", []string{"B753"}},
+		{"Lufthansa Cargo MD-11F", "DLH", []string{"MD11"}},
+		{"Lufthansa Boeing 747-400", "DLH", []string{"B744"}},
+		{"British Airways B737-800", "BAW", []string{"B738"}},
+		{"Unknown Airline", "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			if got := x.Airline(tc.title); got != tc.airline {
+				t.Errorf("Airline == %q, want %q", got, tc.airline)
+			}
+			if got := x.Types(tc.title); !reflect.DeepEqual(got, tc.types) {
+				t.Errorf("Types == %v, want %v", got, tc.types)
+			}
+		})
+	}
+}
+
+const testPackagesHTML = `<html><body>
+<a id="airlines"></a>
+<table>
+<tr><th>#</th><th>Package</th><th>Version</th><th>Download</th></tr>
+<tr><td>1</td><td>Lufthansa Boeing 737-300/500</td><td>2.1</td>
+  <td><a href="http://library.avsim.net/search.php?SearchTerm=woai_dlh_b733.zip">AVSIM</a></td></tr>
+<tr><td>2</td><td>Lufthansa Boeing 737-300/500</td><td>2.1</td>
+  <td><a href="http://library.avsim.net/search.php?SearchTerm=woai_dlh_b733_mirror.zip">Mirror</a></td></tr>
+<tr><td>3</td><td>Condor Boeing 757-300</td><td></td><td></td></tr>
+</table>
+</body></html>`
+
+func TestParsePackages(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testPackagesHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Url, _ = url.Parse(allPackagesURL)
+
+	byTitle := make(map[string]*Package)
+	parsePackages(byTitle, doc, doc.Find("#airlines ~ table").First(), false, testICAO(t))
+
+	want := map[string]*Package{
+		"Lufthansa Boeing 737-300/500": {
+			Title:   "Lufthansa Boeing 737-300/500",
+			Airline: "DLH",
+			Types:   []string{"B733", "B735"},
+			Version: "2.1",
+			Links: []string{
+				"http://library.avsim.net/search.php?SearchTerm=woai_dlh_b733.zip",
+				"http://library.avsim.net/search.php?SearchTerm=woai_dlh_b733_mirror.zip",
+			},
+		},
+		"Condor Boeing 757-300": {
+			Title:   "Condor Boeing 757-300",
+			Airline: "CFG",
+			Types:   []string{"B753"},
+		},
+	}
+	if !reflect.DeepEqual(byTitle, want) {
+		for title, p := range byTitle {
+			t.Logf("%q: %+v", title, p)
+		}
+		t.Errorf("packages differ")
+	}
+
+	q := Query{Airline: "dlh", Type: "B735"}
+	if !q.Match(want["Lufthansa Boeing 737-300/500"]) || q.Match(want["Condor Boeing 757-300"]) {
+		t.Errorf("Query %+v matches the wrong packages", q)
+	}
+}
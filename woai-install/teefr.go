@@ -2,49 +2,82 @@ package main
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
 type teeFileReader struct {
-	r io.ReadCloser
-	f *os.File
+	r     io.ReadCloser
+	f     *os.File
+	fname string
+	size  int64 // expected size, or -1
+	n     int64 // bytes read so far
+	eof   bool
+	err   error // first read or write error
 }
 
 // TeeReader returns a Reader that writes to the named file what it reads from
 // r. All reads from r performed through it are matched with corresponding
 // writes. There is no internal buffering - the write must complete before the
 // read completes. Any error encountered while writing is reported as a read
-// error. The file is truncated before the first write and removed if an error
-// occurs.
-func TeeFileReader(r io.ReadCloser, fname string) (io.ReadCloser, error) {
+// error. If size is not negative, r must yield exactly size bytes;
+// io.ErrUnexpectedEOF is reported otherwise.
+//
+// The data is written to a temporary file in the same directory, which
+// replaces the named file on Close only if r has been read to EOF without
+// errors. Otherwise the temporary file is removed and the named file is left
+// untouched.
+func TeeFileReader(r io.ReadCloser, fname string, size int64) (io.ReadCloser, error) {
 	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
 		return nil, err
 	}
 
-	f, err := os.Create(fname)
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".")
 	if err != nil {
 		return nil, err
 	}
 
-	return &teeFileReader{r, f}, nil
+	return &teeFileReader{r: r, f: f, fname: fname, size: size}, nil
 }
 
 func (t *teeFileReader) Read(p []byte) (n int, err error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+
 	n, err = t.r.Read(p)
 	if n > 0 {
+		t.n += int64(n)
 		if n, err := t.f.Write(p[:n]); err != nil {
-			os.Remove(t.f.Name())
+			t.err = err
 			return n, err
 		}
 	}
-	return
+
+	switch {
+	case err == io.EOF && t.size >= 0 && t.n != t.size:
+		err = io.ErrUnexpectedEOF
+		t.err = err
+	case err == io.EOF:
+		t.eof = true
+	case err != nil:
+		t.err = err
+	}
+	return n, err
 }
 
 func (t *teeFileReader) Close() error {
 	e1 := t.r.Close()
 	e2 := t.f.Close()
 
+	if t.eof && t.err == nil && e2 == nil {
+		e2 = os.Rename(t.f.Name(), t.fname)
+	}
+	if !t.eof || t.err != nil || e2 != nil {
+		os.Remove(t.f.Name())
+	}
+
 	if e1 != nil {
 		return e1
 	}
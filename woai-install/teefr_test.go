@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader yields data and then fails with err instead of io.EOF.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		err = f.err
+	}
+	return n, err
+}
+
+func (f *failingReader) Close() error { return nil }
+
+func TestTeeFileReader(t *testing.T) {
+	errReset := errors.New("connection reset by peer")
+
+	testCases := []struct {
+		name    string
+		r       io.ReadCloser
+		size    int64
+		readAll bool
+		wantErr error
+		want    string // content of the file after Close
+	}{
+		{"complete", ioutil.NopCloser(strings.NewReader("new")), -1, true, nil, "new"},
+		{"content length", ioutil.NopCloser(strings.NewReader("new")), 3, true, nil, "new"},
+		{"read error", &failingReader{strings.NewReader("ne"), errReset}, -1, true, errReset, "old"},
+		{"short", ioutil.NopCloser(strings.NewReader("ne")), 3, true, io.ErrUnexpectedEOF, "old"},
+		{"long", ioutil.NopCloser(strings.NewReader("newer")), 3, true, io.ErrUnexpectedEOF, "old"},
+		{"closed early", ioutil.NopCloser(strings.NewReader("new")), -1, false, nil, "old"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "woai-install")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			fname := filepath.Join(dir, "GET", "allpackages.php")
+			if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(fname, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := TeeFileReader(tc.r, fname, tc.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.readAll {
+				_, err = ioutil.ReadAll(r)
+			} else {
+				_, err = r.Read(make([]byte, 1))
+			}
+			if err != tc.wantErr {
+				t.Errorf("read error == %v, want %v", err, tc.wantErr)
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+
+			b, err := ioutil.ReadFile(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.want {
+				t.Errorf("content == %q, want %q", b, tc.want)
+			}
+
+			fis, err := ioutil.ReadDir(filepath.Dir(fname))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fis) != 1 {
+				t.Errorf("%d files in cache, want 1", len(fis))
+			}
+		})
+	}
+}
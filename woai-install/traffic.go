@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flight plans in packages come in sets of three TTools text files:
+// Aircraft_<set>.txt, Airports_<set>.txt and Flightplans_<set>.txt.
+const (
+	aircraftPlans    = "aircraft"
+	airportsPlans    = "airports"
+	flightplansPlans = "flightplans"
+)
+
+// trafficDir is where the traffic files of the packages are installed,
+// relative to FSXRoot. It is part of the default scenery library, so FSX picks
+// them up without changes to scenery.cfg.
+const trafficDir = "Scenery/World/scenery"
+
+// planRoot is where the merged flight plans of the packages are written,
+// relative to FSXRoot. FSX doesn't read them; they are the TTools sources of
+// the traffic files.
+const planRoot = "World of AI/Flightplans"
+
+// planKind returns the kind of flight plan file name is, and the name of the
+// set it belongs to. kind is empty if name is no flight plan file.
+func planKind(name string) (kind, set string) {
+	base := strings.ToLower(path.Base(name))
+	if !strings.HasSuffix(base, ".txt") {
+		return "", ""
+	}
+	base = strings.TrimSuffix(base, ".txt")
+
+	i := strings.Index(base, "_")
+	if i < 0 {
+		return "", ""
+	}
+	switch kind = base[:i]; kind {
+	case aircraftPlans, airportsPlans, flightplansPlans:
+		return kind, base[i+1:]
+	}
+	return "", ""
+}
+
+// isTrafficFile tells whether name is a compiled traffic file shipped with a
+// package.
+func isTrafficFile(name string) bool {
+	iname := strings.ToLower(name)
+	return strings.HasPrefix(iname, "addon scenery/") &&
+		strings.HasPrefix(path.Base(iname), "traffic") &&
+		strings.HasSuffix(iname, ".bgl")
+}
+
+// FlightPlans merges the flight plan sets of a package.
+type FlightPlans struct {
+	sets map[string]map[string][]string // set name -> kind -> lines
+}
+
+// Add reads a flight plan file of the given kind and set; see planKind.
+func (p *FlightPlans) Add(kind, set string, r io.Reader) error {
+	if p.sets == nil {
+		p.sets = make(map[string]map[string][]string)
+	}
+	if p.sets[set] == nil {
+		p.sets[set] = make(map[string][]string)
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		p.sets[set][kind] = append(p.sets[set][kind], line)
+	}
+	return s.Err()
+}
+
+func (p *FlightPlans) Empty() bool {
+	return len(p.sets) == 0
+}
+
+var acNumberPattern = regexp.MustCompile(`(?i)^AC#(\d+),`)
+
+// Merge returns the lines of the merged aircraft, airports and flightplans
+// files. The AC# numbers of the sets are renumbered so that they don't
+// collide, and duplicate airports are dropped.
+func (p *FlightPlans) Merge() map[string][]string {
+	names := make([]string, 0, len(p.sets))
+	for name := range p.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := make(map[string][]string)
+	airports := make(map[string]bool)
+	offset := 0
+	for _, name := range names {
+		set := p.sets[name]
+
+		max := 0
+		for _, kind := range []string{aircraftPlans, flightplansPlans} {
+			for _, line := range set[kind] {
+				m := acNumberPattern.FindStringSubmatch(line)
+				if m == nil {
+					continue
+				}
+				n, _ := strconv.Atoi(m[1])
+				if n > max {
+					max = n
+				}
+				line = fmt.Sprintf("AC#%d,%s", n+offset, line[len(m[0]):])
+				merged[kind] = append(merged[kind], line)
+			}
+		}
+		offset += max
+
+		for _, line := range set[airportsPlans] {
+			icao := strings.ToUpper(strings.SplitN(line, ",", 2)[0])
+			if airports[icao] {
+				continue
+			}
+			airports[icao] = true
+			merged[airportsPlans] = append(merged[airportsPlans], line)
+		}
+	}
+
+	return merged
+}
+
+// planFileName returns the name of a merged flight plan file of a package,
+// following the naming convention of TTools.
+func planFileName(kind, digest string) string {
+	return strings.ToUpper(kind[:1]) + kind[1:] + "_" + digest[:8] + ".txt"
+}
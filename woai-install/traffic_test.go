@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlightPlansMerge(t *testing.T) {
+	type file struct {
+		kind, set, content string
+	}
+
+	testCases := []struct {
+		name  string
+		files []file
+		want  map[string][]string
+	}{
+		{
+			name: "single set",
+			files: []file{
+				{aircraftPlans, "woai_dlh", "// comment\r\nAC#1,300,\"WoA Lufthansa A320\"\r\n\r\n"},
+				{flightplansPlans, "woai_dlh", "AC#1,D-AIPA,10%,24Hr,IFR,06:00,07:00,300,R,0001,EDDT\r\n"},
+				{airportsPlans, "woai_dlh", "EDDT,N52* 33.54',E13* 17.29',122\r\n"},
+			},
+			want: map[string][]string{
+				aircraftPlans:    {`AC#1,300,"WoA Lufthansa A320"`},
+				flightplansPlans: {"AC#1,D-AIPA,10%,24Hr,IFR,06:00,07:00,300,R,0001,EDDT"},
+				airportsPlans:    {"EDDT,N52* 33.54',E13* 17.29',122"},
+			},
+		},
+		{
+			name: "renumbered",
+			files: []file{
+				{aircraftPlans, "woai_dlh", "AC#1,300,\"WoA Lufthansa A320\"\nAC#2,300,\"WoA Lufthansa A321\"\n"},
+				{flightplansPlans, "woai_dlh", "AC#2,D-AISA,10%,24Hr,IFR\n"},
+				{airportsPlans, "woai_dlh", "EDDT,1\nEDDF,2\n"},
+				{aircraftPlans, "woai_dlh_cityline", "ac#1,300,\"WoA CityLine CRJ9\"\n"},
+				{flightplansPlans, "woai_dlh_cityline", "AC#1,D-ACKA,10%,24Hr,IFR\n"},
+				{airportsPlans, "woai_dlh_cityline", "eddt,1\nEDDM,3\n"},
+			},
+			want: map[string][]string{
+				aircraftPlans: {
+					`AC#1,300,"WoA Lufthansa A320"`,
+					`AC#2,300,"WoA Lufthansa A321"`,
+					`AC#3,300,"WoA CityLine CRJ9"`,
+				},
+				flightplansPlans: {
+					"AC#2,D-AISA,10%,24Hr,IFR",
+					"AC#3,D-ACKA,10%,24Hr,IFR",
+				},
+				airportsPlans: {"EDDT,1", "EDDF,2", "EDDM,3"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var p FlightPlans
+			for _, f := range tc.files {
+				if err := p.Add(f.kind, f.set, strings.NewReader(f.content)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := p.Merge(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Merge() ==\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// readVersion returns the version in a version.ini file: the value of the
+// first key that contains "version", or else the first line that is no
+// section header.
+func readVersion(r io.Reader) string {
+	first := ""
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, ";"), strings.HasPrefix(line, "["):
+			continue
+		case first == "":
+			first = line
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 && strings.Contains(strings.ToLower(kv[0]), "version") {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return first
+}
+
+// packageVersion returns the version of a decrypted package, or the empty
+// string if it has no version.ini.
+func packageVersion(z *zip.Reader) string {
+	for _, zf := range z.File {
+		if !strings.EqualFold(entryName(zf), "version.ini") {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return ""
+		}
+		defer r.Close()
+		return readVersion(r)
+	}
+	return ""
+}
+
+// sameVersion tells whether the versions a and b, from version.ini or the
+// package list, are the same, ignoring case and a "v" prefix.
+func sameVersion(a, b string) bool {
+	norm := func(s string) string {
+		return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v")
+	}
+	return norm(a) == norm(b)
+}
+
+// upgradePackages checks the installed packages whose title matches pattern
+// against a fresh copy of allpackages.php. A package is outdated if the listed
+// version differs from the installed one or, if either version is unknown, if
+// its download page links to a different DLID than the installed one.
+// Outdated packages are printed and, unless dryRun is set, upgraded with the
+// conflict policy.
+func upgradePackages(w io.Writer, pattern, policy string, dryRun bool) error {
+	ms, err := LoadManifests(manifestDir)
+	if err != nil {
+		return err
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", allPackagesURL, nil)
+	if err != nil {
+		return err
+	}
+	os.Remove(cacheFile(req))
+
+	ps, err := search(Query{}, &ICAO{})
+	if err != nil {
+		return err
+	}
+	byTitle := make(map[string]*Package, len(ps))
+	for _, p := range ps {
+		byTitle[p.Title] = p
+	}
+
+	for _, m := range ms {
+		if !re.MatchString(m.Title) {
+			continue
+		}
+		p := byTitle[m.Title]
+		if p == nil || len(p.Links) == 0 {
+			fmt.Fprintf(w, "%s\t%s\tno longer listed\n", m.DLID, m.Title)
+			continue
+		}
+
+		newVersion := m.Version != "" && p.Version != ""
+		if newVersion && sameVersion(m.Version, p.Version) {
+			continue
+		}
+
+		// The download page is only fetched again if the version says
+		// there is something new; otherwise the cached one will do.
+		dlID := ""
+		for _, link := range p.Links {
+			dlID, err = packageDLID(link, newVersion)
+			if err == nil {
+				break
+			}
+		}
+		switch {
+		case dlID == "":
+			fmt.Fprintf(w, "%s\t%s\t%v\n", m.DLID, m.Title, err)
+			continue
+		case dlID == m.DLID && newVersion:
+			fmt.Fprintf(w, "%s\t%s\tlisted as version %s, but not uploaded yet\n", m.DLID, m.Title, p.Version)
+			continue
+		case dlID == m.DLID:
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\tnew DLID %s\n", m.DLID, m.Title, dlID)
+		if dryRun {
+			continue
+		}
+		if err := upgrade(w, m, dlID, policy); err != nil {
+			fmt.Fprintln(w, "\t", err)
+		}
+	}
+
+	return nil
+}
+
+// fetchPackage downloads the package dlID, unless it is cached, makes sure it
+// can be decrypted and returns the file name and the package version. It is a
+// variable for tests.
+var fetchPackage = func(dlID, label string) (fname, version string, err error) {
+	fname, err = downloadDLID(dlID, label)
+	if err != nil {
+		return "", "", err
+	}
+	z, _, err := openPackage(fname, dlID)
+	if err != nil {
+		return "", "", err
+	}
+	return fname, packageVersion(z), nil
+}
+
+// install is installPackage, a variable for tests.
+var install = installPackage
+
+// upgrade replaces the installed package m with the package dlID. If the new
+// package cannot be installed, whatever it left behind is removed and the old
+// package is installed again.
+func upgrade(w io.Writer, m *Manifest, dlID, policy string) error {
+	fname, version, err := fetchPackage(dlID, m.Title)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\tversion %q -> %q\n", m.Version, version)
+
+	// Downloads of the installed package are usually cached still.
+	oldFname, _, err := fetchPackage(m.DLID, m.Title)
+	if err != nil {
+		return fmt.Errorf("Cannot keep installed package for a rollback: %v", err)
+	}
+
+	others, err := LoadManifests(manifestDir)
+	if err != nil {
+		return err
+	}
+	if err := uninstall(FSXRoot, manifestDir, m, others); err != nil {
+		return err
+	}
+
+	err = install(fname, dlID, m.Title, policy)
+	if err == nil {
+		return nil
+	}
+
+	fmt.Fprintf(w, "\t%v\n\treinstalling %s\n", err, m.DLID)
+	ms, e := LoadManifests(manifestDir)
+	if e != nil {
+		return e
+	}
+	for _, n := range ms {
+		if n.DLID != dlID {
+			continue
+		}
+		if e := uninstall(FSXRoot, manifestDir, n, ms); e != nil {
+			return e
+		}
+	}
+	if e := install(oldFname, m.DLID, m.Title, policy); e != nil {
+		return fmt.Errorf("Cannot reinstall %s: %v", m.DLID, e)
+	}
+	return fmt.Errorf("Upgrade to %s failed: %v", dlID, err)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadVersion(t *testing.T) {
+	testCases := []struct {
+		ini  string
+		want string
+	}{
+		{"", ""},
+		{"[WoAI]\r\nVersion=2.1\r\n", "2.1"},
+		{"; comment\nPackage=WoA DLH\nPackageVersion = 3.0 \n", "3.0"},
+		{"[WoAI]\nv1.5\n", "v1.5"},
+	}
+
+	for _, tc := range testCases {
+		if got := readVersion(strings.NewReader(tc.ini)); got != tc.want {
+			t.Errorf("readVersion(%q) == %q, want %q", tc.ini, got, tc.want)
+		}
+	}
+}
+
+func TestSameVersion(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want bool
+	}{
+		{"2.1", "2.1", true},
+		{"v2.1", " 2.1", true},
+		{"V2.1", "2.1", true},
+		{"2.1", "2.2", false},
+	}
+
+	for _, tc := range testCases {
+		if got := sameVersion(tc.a, tc.b); got != tc.want {
+			t.Errorf("sameVersion(%q, %q) == %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "woai-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	defer func(root string) { FSXRoot = root }(FSXRoot)
+	FSXRoot = filepath.Join(dir, "FSX")
+	defer func(f func(string, string) (string, string, error)) { fetchPackage = f }(fetchPackage)
+	defer func(f func(string, string, string, string) error) { install = f }(install)
+
+	// Packages install a single aircraft.cfg that contains their DLID;
+	// broken ones fail afterwards.
+	broken := map[string]bool{}
+	fetchPackage = func(dlID, label string) (string, string, error) {
+		return dlID + ".zip", "v" + dlID, nil
+	}
+	install = func(fname, dlID, title, policy string) error {
+		rel := "SimObjects/Airplanes/" + dlID + "/aircraft.cfg"
+		fname = filepath.Join(FSXRoot, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fname, []byte(dlID), 0644); err != nil {
+			return err
+		}
+		sum, err := fileSHA1(fname)
+		if err != nil {
+			return err
+		}
+		m := &Manifest{DLID: dlID, Title: title, Version: "v" + dlID, Files: []ManifestFile{{rel, sum}}}
+		if err := m.Save(manifestDir); err != nil {
+			return err
+		}
+		if broken[dlID] {
+			return errors.New("broken")
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		name    string
+		dlID    string
+		wantErr bool
+		want    string // DLID of the installed package afterwards
+	}{
+		{"broken", "2", true, "1"},
+		{"ok", "3", false, "3"},
+	}
+	broken["2"] = true
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.RemoveAll(manifestDir)
+			os.RemoveAll(FSXRoot)
+			if err := install("1.zip", "1", "WoAI Lufthansa", "overwrite"); err != nil {
+				t.Fatal(err)
+			}
+			ms, err := LoadManifests(manifestDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = upgrade(&buf, ms[0], tc.dlID, "overwrite")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("upgrade() == %v, want error: %v", err, tc.wantErr)
+			}
+
+			ms, err = LoadManifests(manifestDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range ms {
+				got = append(got, m.DLID)
+			}
+			if want := []string{tc.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("installed packages == %v, want %v", got, want)
+			}
+
+			fis, err := ioutil.ReadDir(filepath.Join(FSXRoot, "SimObjects/Airplanes"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = nil
+			for _, fi := range fis {
+				got = append(got, fi.Name())
+			}
+			if want := []string{tc.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("installed aircraft == %v, want %v", got, want)
+			}
+		})
+	}
+}